@@ -0,0 +1,201 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admin provides a client for the internal API of localstack (/_localstack/*).
+// See https://docs.localstack.cloud/references/internal-endpoints/
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Client calls the internal API of a localstack instance.
+type Client struct {
+	endpoint string
+	client   *http.Client
+}
+
+// New creates a client for the localstack at the given endpoint (e.g. http://localhost:4566).
+// It uses http.DefaultClient, when client is nil.
+func New(endpoint string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{endpoint: endpoint, client: client}
+}
+
+// Health is the health of localstack and its services.
+type Health struct {
+	Edition string `json:"edition"`
+	Version string `json:"version"`
+	// Services maps the names of services to their state (e.g. available, running or disabled).
+	Services map[string]string `json:"services"`
+}
+
+// Health returns the health of localstack and its services.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var h Health
+	if err := c.do(ctx, http.MethodGet, "/_localstack/health", nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Info describes the running localstack.
+type Info struct {
+	Version            string `json:"version"`
+	Edition            string `json:"edition"`
+	IsLicenseActivated bool   `json:"is_license_activated"`
+	SessionID          string `json:"session_id"`
+	MachineID          string `json:"machine_id"`
+	System             string `json:"system"`
+	IsDocker           bool   `json:"is_docker"`
+	ServerTimeUTC      string `json:"server_time_utc"`
+	// Uptime is the time localstack is running in seconds.
+	Uptime int `json:"uptime"`
+}
+
+// Info returns information about the running localstack.
+func (c *Client) Info(ctx context.Context) (*Info, error) {
+	var info Info
+	if err := c.do(ctx, http.MethodGet, "/_localstack/info", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// InitStatus is the status of the init hooks.
+type InitStatus struct {
+	// Completed maps the stages (BOOT, START, READY and SHUTDOWN) to whether they completed.
+	Completed map[string]bool `json:"completed"`
+	Scripts   []InitScript    `json:"scripts"`
+}
+
+// InitScript is the status of an init hook.
+type InitScript struct {
+	Stage string `json:"stage"`
+	Name  string `json:"name"`
+	// State is one of UNKNOWN, RUNNING, SUCCESSFUL or ERROR.
+	State string `json:"state"`
+}
+
+// Init returns the status of the init hooks.
+func (c *Client) Init(ctx context.Context) (*InitStatus, error) {
+	var status InitStatus
+	if err := c.do(ctx, http.MethodGet, "/_localstack/init", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Config returns the runtime configuration of localstack.
+// It requires localstack to be started with ENABLE_CONFIG_UPDATES=1.
+func (c *Client) Config(ctx context.Context) (map[string]any, error) {
+	var config map[string]any
+	if err := c.do(ctx, http.MethodGet, "/_localstack/config", nil, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// UpdateConfig sets a variable of the runtime configuration of localstack.
+// It requires localstack to be started with ENABLE_CONFIG_UPDATES=1.
+func (c *Client) UpdateConfig(ctx context.Context, variable string, value any) error {
+	return c.do(ctx, http.MethodPost, "/_localstack/config", map[string]any{
+		"variable": variable,
+		"value":    value,
+	}, nil)
+}
+
+// Diagnose returns the diagnostic report of localstack.
+// It requires localstack to be started with DEBUG=1.
+func (c *Client) Diagnose(ctx context.Context) (map[string]any, error) {
+	var diagnosis map[string]any
+	if err := c.do(ctx, http.MethodGet, "/_localstack/diagnose", nil, &diagnosis); err != nil {
+		return nil, err
+	}
+	return diagnosis, nil
+}
+
+// PluginNamespace contains the plugins of a namespace.
+type PluginNamespace struct {
+	Namespace string   `json:"namespace"`
+	Plugins   []Plugin `json:"plugins"`
+}
+
+// Plugin is the state of a localstack plugin.
+type Plugin struct {
+	Name          string `json:"name"`
+	IsInitialized bool   `json:"is_initialized"`
+	IsLoaded      bool   `json:"is_loaded"`
+	InitError     string `json:"init_error,omitempty"`
+	LoadError     string `json:"load_error,omitempty"`
+}
+
+// Plugins returns the plugins of localstack by namespace.
+func (c *Client) Plugins(ctx context.Context) ([]PluginNamespace, error) {
+	var plugins []PluginNamespace
+	if err := c.do(ctx, http.MethodGet, "/_localstack/plugins", nil, &plugins); err != nil {
+		return nil, err
+	}
+	return plugins, nil
+}
+
+// ResetState discards the resources of the given service or of all services, when service is empty.
+func (c *Client) ResetState(ctx context.Context, service string) error {
+	path := "/_localstack/state/reset"
+	if service != "" {
+		path = "/_localstack/state/" + service + "/reset"
+	}
+	return c.do(ctx, http.MethodPost, path, nil, nil)
+}
+
+// do sends the request body as JSON and decodes the response into v, when not nil.
+func (c *Client) do(ctx context.Context, method string, path string, body any, v any) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s", path, res.Status)
+	}
+	if v == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return err
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elgohr/go-localstack/admin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when     string
+		method   string
+		path     string
+		request  string
+		response string
+		call     func(t *testing.T, c *admin.Client)
+	}{
+		{
+			when:     "getting the health",
+			method:   http.MethodGet,
+			path:     "/_localstack/health",
+			response: `{"edition": "community", "version": "4.0.0", "services": {"s3": "running"}}`,
+			call: func(t *testing.T, c *admin.Client) {
+				h, err := c.Health(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, &admin.Health{
+					Edition:  "community",
+					Version:  "4.0.0",
+					Services: map[string]string{"s3": "running"},
+				}, h)
+			},
+		},
+		{
+			when:     "getting the info",
+			method:   http.MethodGet,
+			path:     "/_localstack/info",
+			response: `{"version": "4.0.0", "edition": "pro", "is_license_activated": true, "session_id": "session", "machine_id": "machine", "system": "linux", "is_docker": true, "server_time_utc": "2026-01-01T00:00:00", "uptime": 42}`,
+			call: func(t *testing.T, c *admin.Client) {
+				info, err := c.Info(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, &admin.Info{
+					Version:            "4.0.0",
+					Edition:            "pro",
+					IsLicenseActivated: true,
+					SessionID:          "session",
+					MachineID:          "machine",
+					System:             "linux",
+					IsDocker:           true,
+					ServerTimeUTC:      "2026-01-01T00:00:00",
+					Uptime:             42,
+				}, info)
+			},
+		},
+		{
+			when:     "getting the init status",
+			method:   http.MethodGet,
+			path:     "/_localstack/init",
+			response: `{"completed": {"READY": true}, "scripts": [{"stage": "READY", "name": "seed.sh", "state": "SUCCESSFUL"}]}`,
+			call: func(t *testing.T, c *admin.Client) {
+				status, err := c.Init(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, &admin.InitStatus{
+					Completed: map[string]bool{"READY": true},
+					Scripts:   []admin.InitScript{{Stage: "READY", Name: "seed.sh", State: "SUCCESSFUL"}},
+				}, status)
+			},
+		},
+		{
+			when:     "getting the config",
+			method:   http.MethodGet,
+			path:     "/_localstack/config",
+			response: `{"DEBUG": true, "GATEWAY_LISTEN": "0.0.0.0:4566"}`,
+			call: func(t *testing.T, c *admin.Client) {
+				config, err := c.Config(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, map[string]any{"DEBUG": true, "GATEWAY_LISTEN": "0.0.0.0:4566"}, config)
+			},
+		},
+		{
+			when:     "updating the config",
+			method:   http.MethodPost,
+			path:     "/_localstack/config",
+			request:  `{"value":"trace","variable":"LS_LOG"}`,
+			response: `{"variable": "LS_LOG", "value": "trace"}`,
+			call: func(t *testing.T, c *admin.Client) {
+				require.NoError(t, c.UpdateConfig(context.Background(), "LS_LOG", "trace"))
+			},
+		},
+		{
+			when:     "diagnosing",
+			method:   http.MethodGet,
+			path:     "/_localstack/diagnose",
+			response: `{"version": {"image-version": "4.0.0"}}`,
+			call: func(t *testing.T, c *admin.Client) {
+				diagnosis, err := c.Diagnose(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, map[string]any{"version": map[string]any{"image-version": "4.0.0"}}, diagnosis)
+			},
+		},
+		{
+			when:     "getting the plugins",
+			method:   http.MethodGet,
+			path:     "/_localstack/plugins",
+			response: `[{"namespace": "localstack.aws.provider", "plugins": [{"name": "s3:default", "is_initialized": true, "is_loaded": true}]}]`,
+			call: func(t *testing.T, c *admin.Client) {
+				plugins, err := c.Plugins(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, []admin.PluginNamespace{{
+					Namespace: "localstack.aws.provider",
+					Plugins:   []admin.Plugin{{Name: "s3:default", IsInitialized: true, IsLoaded: true}},
+				}}, plugins)
+			},
+		},
+		{
+			when:   "resetting all state",
+			method: http.MethodPost,
+			path:   "/_localstack/state/reset",
+			call: func(t *testing.T, c *admin.Client) {
+				require.NoError(t, c.ResetState(context.Background(), ""))
+			},
+		},
+		{
+			when:   "resetting the state of a service",
+			method: http.MethodPost,
+			path:   "/_localstack/state/sqs/reset",
+			call: func(t *testing.T, c *admin.Client) {
+				require.NoError(t, c.ResetState(context.Background(), "sqs"))
+			},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, s.method, r.Method)
+				require.Equal(t, s.path, r.URL.Path)
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, s.request, string(body))
+				_, _ = w.Write([]byte(s.response))
+			}))
+			t.Cleanup(server.Close)
+
+			s.call(t, admin.New(server.URL, server.Client()))
+		})
+	}
+}
+
+func TestClient_Fails(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+	c := admin.New(server.URL, nil)
+
+	_, err := c.Config(context.Background())
+	require.EqualError(t, err, "/_localstack/config 400 Bad Request")
+	require.EqualError(t, c.UpdateConfig(context.Background(), "DEBUG", 1), "/_localstack/config 400 Bad Request")
+}
+
+func TestClient_Fails_Unreachable(t *testing.T) {
+	t.Parallel()
+	_, err := admin.New("", nil).Health(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/client"
+)
+
+// WithImageArchive configures a tarball of the image (see SaveImage),
+// which is loaded when the image is not available locally.
+// Together with WithPullPolicy(PullNever) localstack starts without registry access.
+// The image is pulled as usual (see WithPullPolicy), when the archive doesn't exist.
+func WithImageArchive(path string) InstanceOption {
+	return func(i *Instance) {
+		i.imageArchive = path
+	}
+}
+
+// SaveImage writes the image of the instance (see WithImage and WithVersion) as a tarball to path,
+// for being loaded by WithImageArchive later on. The image is pulled, when it's not available locally.
+func (i *Instance) SaveImage(ctx context.Context, path string) error {
+	if _, err := i.ensureImage(ctx); err != nil {
+		return fmt.Errorf("localstack: %w", err)
+	}
+	if err := i.saveImage(ctx, path); err != nil {
+		return fmt.Errorf("localstack: could not save image: %w", err)
+	}
+	return nil
+}
+
+func (i *Instance) saveImage(ctx context.Context, path string) error {
+	reader, err := i.cli.ImageSave(ctx, []string{i.imageRef()})
+	if err != nil {
+		return err
+	}
+	defer logClose(reader)
+
+	// written next to the archive and renamed, so that an aborted save leaves no broken archive
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := io.Copy(tmp, reader); err != nil {
+		logClose(tmp)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadImage loads the image archive and returns false, when it doesn't exist.
+func (i *Instance) loadImage(ctx context.Context) (bool, error) {
+	archive, err := os.Open(i.imageArchive)
+	if errors.Is(err, fs.ErrNotExist) {
+		i.log.Infof("image archive %s doesn't exist", i.imageArchive)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer logClose(archive)
+
+	i.log.Infof("loading %s from %s", i.imageRef(), i.imageArchive)
+	res, err := i.cli.ImageLoad(ctx, archive, client.ImageLoadWithQuiet(true))
+	if err != nil {
+		return false, fmt.Errorf("could not load %s: %w", i.imageArchive, err)
+	}
+	defer logClose(res.Body)
+	if !res.JSON {
+		_, err = io.Copy(io.Discard, res.Body)
+	} else {
+		err = readPullStream(res.Body, func(event PullEvent) {
+			i.log.Debugf("loading %s: %s", i.imageRef(), event.Status)
+		})
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not load %s: %w", i.imageArchive, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_ensureImage_Archive(t *testing.T) {
+	t.Parallel()
+	archive := filepath.Join(t.TempDir(), "localstack.tar")
+	require.NoError(t, os.WriteFile(archive, []byte("image"), 0o600))
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturnsOnCall(0, image.InspectResponse{}, cerrdefs.ErrNotFound)
+	f.ImageInspectReturnsOnCall(1, image.InspectResponse{ID: "sha256:loaded"}, nil)
+	var loaded []byte
+	f.ImageLoadStub = func(_ context.Context, input io.Reader, _ ...client.ImageLoadOption) (image.LoadResponse, error) {
+		var err error
+		loaded, err = io.ReadAll(input)
+		return image.LoadResponse{Body: io.NopCloser(strings.NewReader(`{"stream": "Loaded image"}`)), JSON: true}, err
+	}
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+	WithImageArchive(archive)(i)
+	WithPullPolicy(PullNever)(i)
+
+	imageId, err := i.ensureImage(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "sha256:loaded", imageId)
+	require.Equal(t, 0, f.ImagePullCallCount())
+	require.Equal(t, "image", string(loaded))
+}
+
+func TestInstance_ensureImage_Archive_Missing(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+	WithImageArchive(filepath.Join(t.TempDir(), "missing.tar"))(i)
+	WithPullPolicy(PullNever)(i)
+
+	_, err := i.ensureImage(context.Background())
+	require.EqualError(t, err, "localstack/localstack:4.0.0 is not available locally and pulling is disabled")
+	require.Equal(t, 0, f.ImageLoadCallCount())
+}
+
+func TestInstance_ensureImage_Archive_Fails(t *testing.T) {
+	t.Parallel()
+	archive := filepath.Join(t.TempDir(), "localstack.tar")
+	require.NoError(t, os.WriteFile(archive, []byte("image"), 0o600))
+	for _, scenario := range [...]struct {
+		when   string
+		given  func(f *internalfakes.FakeDockerClient)
+		expect string
+	}{
+		{
+			when: "loading fails",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageLoadReturns(image.LoadResponse{}, errors.New("can't load"))
+			},
+			expect: "could not load " + archive + ": can't load",
+		},
+		{
+			when: "the archive is invalid",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageLoadReturns(image.LoadResponse{Body: io.NopCloser(strings.NewReader(`{"error": "invalid tar header"}`)), JSON: true}, nil)
+			},
+			expect: "could not load " + archive + ": invalid tar header",
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+			s.given(f)
+			i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+			WithImageArchive(archive)(i)
+
+			_, err := i.ensureImage(context.Background())
+			require.EqualError(t, err, s.expect)
+			require.Equal(t, 0, f.ImagePullCallCount())
+		})
+	}
+}
+
+func TestInstance_Start_Fails_Archive(t *testing.T) {
+	t.Parallel()
+	archive := filepath.Join(t.TempDir(), "localstack.tar")
+	require.NoError(t, os.WriteFile(archive, []byte("image"), 0o600))
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+	f.ImageLoadReturns(image.LoadResponse{}, errors.New("can't load"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+	WithImageArchive(archive)(i)
+
+	require.EqualError(t, i.Start(), "localstack: could not load "+archive+": can't load")
+	require.Equal(t, 0, f.ImagePullCallCount())
+}
+
+func TestInstance_SaveImage(t *testing.T) {
+	t.Parallel()
+	archive := filepath.Join(t.TempDir(), "localstack.tar")
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageSaveReturns(io.NopCloser(strings.NewReader("image")), nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+
+	require.NoError(t, i.SaveImage(context.Background(), archive))
+
+	_, images, _ := f.ImageSaveArgsForCall(0)
+	require.Equal(t, []string{"localstack/localstack:4.0.0"}, images)
+	content, err := os.ReadFile(archive)
+	require.NoError(t, err)
+	require.Equal(t, "image", string(content))
+	entries, err := os.ReadDir(filepath.Dir(archive))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestInstance_SaveImage_Fails(t *testing.T) {
+	t.Parallel()
+	archive := filepath.Join(t.TempDir(), "localstack.tar")
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageSaveReturns(ErrCloser(iotest.ErrReader(errors.New("can't read")), nil), nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+
+	require.EqualError(t, i.SaveImage(context.Background(), archive), "localstack: could not save image: can't read")
+	entries, err := os.ReadDir(filepath.Dir(archive))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
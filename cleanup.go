@@ -0,0 +1,178 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+
+	"github.com/elgohr/go-localstack/internal"
+)
+
+// legacyImage is the image, which was built by previous versions for limiting the lifetime of containers.
+// Every build left the previous image behind untagged.
+const legacyImage = "go-localstack"
+
+// legacyEntrypoint identifies the untagged builds of legacyImage.
+const legacyEntrypoint = "./timeout-entrypoint.sh"
+
+// CleanupOptions selects the containers and images removed by Cleanup.
+type CleanupOptions struct {
+	// Filter selects the containers.
+	// Images built by previous versions don't have labels and are only selected by an empty filter.
+	Filter
+	// OlderThan selects containers and images, which were created at least this long ago.
+	OlderThan time.Duration
+	// Running selects running containers as well. Otherwise, only stopped and orphaned containers are selected.
+	Running bool
+	// DryRun reports the selected containers and images without removing them.
+	DryRun bool
+}
+
+// Image is an image created by go-localstack.
+type Image struct {
+	ID      string
+	Tags    []string
+	Created time.Time
+	Size    int64
+}
+
+// CleanupReport contains the containers and images, which were removed by Cleanup.
+type CleanupReport struct {
+	Containers []Container
+	Images     []Image
+}
+
+// Cleanup removes the containers and images created by go-localstack, which are selected by the options.
+// On failure, the report contains what was removed before.
+func Cleanup(ctx context.Context, opts CleanupOptions) (CleanupReport, error) {
+	cli, err := newDockerClient(ctx)
+	if err != nil {
+		return CleanupReport{}, err
+	}
+	defer logClose(cli)
+	return cleanup(ctx, cli, opts, time.Now())
+}
+
+func cleanup(ctx context.Context, cli internal.DockerClient, opts CleanupOptions, now time.Time) (CleanupReport, error) {
+	report := CleanupReport{}
+	containers, err := cleanupContainers(ctx, cli, opts, now)
+	if err != nil {
+		return report, err
+	}
+	for _, c := range containers {
+		if !opts.DryRun {
+			if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true}); err != nil {
+				return report, fmt.Errorf("localstack: could not remove container %s: %w", c.ID, err)
+			}
+		}
+		report.Containers = append(report.Containers, c)
+	}
+
+	if !opts.Filter.isEmpty() {
+		return report, nil
+	}
+	images, err := legacyImages(ctx, cli)
+	if err != nil {
+		return report, err
+	}
+	for _, img := range images {
+		if !isOlderThan(img.Created, opts.OlderThan, now) {
+			continue
+		}
+		if !opts.DryRun {
+			if _, err := cli.ImageRemove(ctx, img.ID, image.RemoveOptions{PruneChildren: true}); err != nil {
+				return report, fmt.Errorf("localstack: could not remove image %s: %w", img.ID, err)
+			}
+		}
+		report.Images = append(report.Images, img)
+	}
+	return report, nil
+}
+
+// cleanupContainers returns the containers selected by the options.
+func cleanupContainers(ctx context.Context, cli internal.DockerClient, opts CleanupOptions, now time.Time) ([]Container, error) {
+	containers, err := list(ctx, cli, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]Container, 0, len(containers))
+	for _, c := range containers {
+		if !isOlderThan(c.Created, opts.OlderThan, now) {
+			continue
+		}
+		if c.State == container.StateRunning && !opts.Running && !isOrphaned(c.Labels, host) {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+// legacyImages returns the images built by previous versions, tagged or not.
+func legacyImages(ctx context.Context, cli internal.DockerClient) ([]Image, error) {
+	tagged, err := cli.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", legacyImage)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("localstack: could not list images: %w", err)
+	}
+	dangling, err := cli.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("dangling", "true")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("localstack: could not list images: %w", err)
+	}
+	for _, summary := range dangling {
+		inspect, err := cli.ImageInspect(ctx, summary.ID)
+		if err != nil {
+			return nil, fmt.Errorf("localstack: could not inspect image %s: %w", summary.ID, err)
+		}
+		if inspect.Config != nil && slices.Equal(inspect.Config.Entrypoint, []string{legacyEntrypoint}) {
+			tagged = append(tagged, summary)
+		}
+	}
+
+	images := make([]Image, 0, len(tagged))
+	for _, summary := range tagged {
+		images = append(images, Image{
+			ID:      summary.ID,
+			Tags:    summary.RepoTags,
+			Created: time.Unix(summary.Created, 0),
+			Size:    summary.Size,
+		})
+	}
+	return images, nil
+}
+
+func isOlderThan(created time.Time, age time.Duration, now time.Time) bool {
+	return age <= 0 || !created.After(now.Add(-age))
+}
+
+// isEmpty returns true, when the filter selects all containers created by go-localstack.
+func (f Filter) isEmpty() bool {
+	return f.ID == "" && f.Session == "" && len(f.Labels) == 0 && !f.Orphaned
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanup(t *testing.T) {
+	t.Parallel()
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	old := now.Add(-48 * time.Hour).Unix()
+	containers := []container.Summary{
+		{ID: "exited", State: container.StateExited, Created: old},
+		{ID: "running", State: container.StateRunning, Created: old, Labels: map[string]string{labelHost: host, labelPid: strconv.Itoa(os.Getpid())}},
+		{ID: "orphaned", State: container.StateRunning, Created: old, Labels: map[string]string{labelHost: host, labelPid: deadPid}},
+		{ID: "recent", State: container.StateExited, Created: now.Unix()},
+	}
+	images := []image.Summary{
+		{ID: "sha256:tagged", RepoTags: []string{"go-localstack:latest"}, Created: old, Size: 1},
+	}
+	dangling := []image.Summary{
+		{ID: "sha256:legacy", Created: old, Size: 2},
+		{ID: "sha256:other", Created: old, Size: 3},
+		{ID: "sha256:recent", Created: now.Unix(), Size: 4},
+	}
+
+	for _, scenario := range [...]struct {
+		when             string
+		opts             CleanupOptions
+		expectContainers []string
+		expectImages     []string
+	}{
+		{
+			when:             "cleaning up everything",
+			expectContainers: []string{"exited", "orphaned", "recent"},
+			expectImages:     []string{"sha256:tagged", "sha256:legacy", "sha256:recent"},
+		},
+		{
+			when:             "cleaning up running containers",
+			opts:             CleanupOptions{Running: true},
+			expectContainers: []string{"exited", "running", "orphaned", "recent"},
+			expectImages:     []string{"sha256:tagged", "sha256:legacy", "sha256:recent"},
+		},
+		{
+			when:             "cleaning up old containers and images",
+			opts:             CleanupOptions{OlderThan: 24 * time.Hour},
+			expectContainers: []string{"exited", "orphaned"},
+			expectImages:     []string{"sha256:tagged", "sha256:legacy"},
+		},
+		{
+			when:             "cleaning up containers with labels",
+			opts:             CleanupOptions{Filter: Filter{Labels: map[string]string{"team": "a"}}},
+			expectContainers: []string{"exited", "orphaned", "recent"},
+		},
+		{
+			when:             "cleaning up in a dry run",
+			opts:             CleanupOptions{DryRun: true},
+			expectContainers: []string{"exited", "orphaned", "recent"},
+			expectImages:     []string{"sha256:tagged", "sha256:legacy", "sha256:recent"},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			f.ContainerListReturns(append([]container.Summary{}, containers...), nil)
+			f.ImageListStub = func(_ context.Context, options image.ListOptions) ([]image.Summary, error) {
+				if options.Filters.Contains("dangling") {
+					return append([]image.Summary{}, dangling...), nil
+				}
+				return append([]image.Summary{}, images...), nil
+			}
+			f.ImageInspectStub = func(_ context.Context, id string, _ ...client.ImageInspectOption) (image.InspectResponse, error) {
+				entrypoint := []string{legacyEntrypoint}
+				if id == "sha256:other" {
+					entrypoint = []string{"docker-entrypoint.sh"}
+				}
+				return image.InspectResponse{
+					ID:     id,
+					Config: &dockerspec.DockerOCIImageConfig{ImageConfig: ocispec.ImageConfig{Entrypoint: entrypoint}},
+				}, nil
+			}
+
+			report, err := cleanup(context.Background(), f, s.opts, now)
+			require.NoError(t, err)
+
+			var removedContainers []string
+			for _, c := range report.Containers {
+				removedContainers = append(removedContainers, c.ID)
+			}
+			require.Equal(t, s.expectContainers, removedContainers)
+			var removedImages []string
+			for _, img := range report.Images {
+				removedImages = append(removedImages, img.ID)
+			}
+			require.Equal(t, s.expectImages, removedImages)
+
+			if s.opts.DryRun {
+				require.Equal(t, 0, f.ContainerRemoveCallCount())
+				require.Equal(t, 0, f.ImageRemoveCallCount())
+				return
+			}
+			require.Equal(t, len(s.expectContainers), f.ContainerRemoveCallCount())
+			for n, id := range s.expectContainers {
+				_, removedId, options := f.ContainerRemoveArgsForCall(n)
+				require.Equal(t, id, removedId)
+				require.True(t, options.Force)
+			}
+			require.Equal(t, len(s.expectImages), f.ImageRemoveCallCount())
+			for n, id := range s.expectImages {
+				_, removedId, options := f.ImageRemoveArgsForCall(n)
+				require.Equal(t, id, removedId)
+				require.True(t, options.PruneChildren)
+			}
+		})
+	}
+}
+
+func TestCleanup_Report(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerListReturns([]container.Summary{{ID: "exited", State: container.StateExited, Created: 1}}, nil)
+	f.ImageListReturnsOnCall(0, []image.Summary{{ID: "sha256:tagged", RepoTags: []string{"go-localstack:latest"}, Created: 2, Size: 3}}, nil)
+
+	report, err := cleanup(context.Background(), f, CleanupOptions{}, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, CleanupReport{
+		Containers: []Container{{ID: "exited", State: container.StateExited, Created: time.Unix(1, 0)}},
+		Images:     []Image{{ID: "sha256:tagged", Tags: []string{"go-localstack:latest"}, Created: time.Unix(2, 0), Size: 3}},
+	}, report)
+
+	_, options := f.ImageListArgsForCall(0)
+	require.Equal(t, filters.NewArgs(filters.Arg("reference", legacyImage)), options.Filters)
+	_, options = f.ImageListArgsForCall(1)
+	require.Equal(t, filters.NewArgs(filters.Arg("dangling", "true")), options.Filters)
+}
+
+func TestCleanup_Fails(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when   string
+		given  func(f *internalfakes.FakeDockerClient)
+		expect string
+	}{
+		{
+			when: "containers can't be listed",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ContainerListReturns(nil, errors.New("can't list"))
+			},
+			expect: "localstack: could not list containers: can't list",
+		},
+		{
+			when: "a container can't be removed",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ContainerListReturns([]container.Summary{{ID: "exited"}}, nil)
+				f.ContainerRemoveReturns(errors.New("can't remove"))
+			},
+			expect: "localstack: could not remove container exited: can't remove",
+		},
+		{
+			when: "images can't be listed",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageListReturns(nil, errors.New("can't list"))
+			},
+			expect: "localstack: could not list images: can't list",
+		},
+		{
+			when: "dangling images can't be listed",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageListReturnsOnCall(1, nil, errors.New("can't list"))
+			},
+			expect: "localstack: could not list images: can't list",
+		},
+		{
+			when: "an image can't be inspected",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageListReturnsOnCall(1, []image.Summary{{ID: "sha256:dangling"}}, nil)
+				f.ImageInspectReturns(image.InspectResponse{}, errors.New("can't inspect"))
+			},
+			expect: "localstack: could not inspect image sha256:dangling: can't inspect",
+		},
+		{
+			when: "an image can't be removed",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageListReturnsOnCall(0, []image.Summary{{ID: "sha256:tagged"}}, nil)
+				f.ImageRemoveReturns(nil, errors.New("can't remove"))
+			},
+			expect: "localstack: could not remove image sha256:tagged: can't remove",
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			s.given(f)
+			_, err := cleanup(context.Background(), f, CleanupOptions{}, time.Now())
+			require.EqualError(t, err, s.expect)
+		})
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command golocalstack starts and inspects localstack containers with the configuration
+// of go-localstack, so that they can be used by hand (e.g. with the AWS CLI).
+//
+//	golocalstack up -services s3,sqs -label team=a
+//	eval "$(golocalstack env -label team=a)"
+//	golocalstack status
+//	golocalstack down -label team=a
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/elgohr/go-localstack"
+)
+
+const usage = `usage: golocalstack <command> [flags]
+
+commands:
+  up       start localstack and wait until it's ready
+  down     remove the containers matching the flags
+  status   list the containers created by go-localstack
+  env      print the environment for the AWS CLI and SDKs
+  cleanup  remove stopped and orphaned containers and images of previous versions
+  doctor   check whether localstack can start on this machine
+
+Run 'golocalstack <command> -h' for the flags of a command.
+`
+
+// region is the default region of localstack.
+const region = "us-east-1"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var command func(ctx context.Context, args []string, stdout, stderr io.Writer) error
+	switch args[0] {
+	case "up":
+		command = up
+	case "down":
+		command = down
+	case "status":
+		command = status
+	case "env":
+		command = env
+	case "cleanup":
+		command = cleanup
+	case "doctor":
+		command = doctor
+	case "help", "-h", "-help", "--help":
+		_, _ = fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		_, _ = fmt.Fprintf(stderr, "golocalstack: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	if err := command(ctx, args[1:], stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		_, _ = fmt.Fprintln(stderr, "golocalstack:", err)
+		return 1
+	}
+	return 0
+}
+
+func up(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("up", stderr)
+	version := flags.String("version", localstack.LatestVersion, "version of localstack")
+	image := flags.String("image", "", "repository of the localstack image (default \"localstack/localstack\")")
+	services := flags.String("services", "", "comma separated services to start, e.g. s3,sqs (default all)")
+	authToken := flags.String("auth-token", os.Getenv("LOCALSTACK_AUTH_TOKEN"), "auth token of localstack (default $LOCALSTACK_AUTH_TOKEN)")
+	lease := flags.Duration("lease", 0, "lifetime of the container without a heartbeat, 0 keeps it running until 'down'")
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for localstack to become ready")
+	labels := labelFlag{}
+	flags.Var(labels, "label", "label of the container as key=value, can be repeated")
+	environment := labelFlag{}
+	flags.Var(environment, "env", "environment variable of localstack as key=value, can be repeated")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	selected, err := parseServices(*services)
+	if err != nil {
+		return err
+	}
+
+	opts := []localstack.InstanceOption{
+		localstack.WithVersion(*version),
+		localstack.WithReuse(),
+		localstack.WithLease(*lease),
+	}
+	if *image != "" {
+		opts = append(opts, localstack.WithImage(*image))
+	}
+	if len(labels) > 0 {
+		opts = append(opts, localstack.WithLabels(labels))
+	}
+	if len(environment) > 0 {
+		opts = append(opts, localstack.WithEnv(environment))
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	i, err := localstack.NewAuthenticatedInstanceWithContext(ctx, *authToken, opts...)
+	if err != nil {
+		return err
+	}
+	if err := i.StartCtx(ctx, selected...); err != nil {
+		return removeUnready(stderr, localstack.Reap, i.ContainerID(), err)
+	}
+	return printEndpoints(stdout, i, selected)
+}
+
+// removeUnready removes the container, which didn't become ready (e.g. on timeout or interrupt),
+// as it has no lease that would end it.
+func removeUnready(stderr io.Writer, reap func(context.Context, localstack.Filter) ([]string, error), id string, err error) error {
+	if id == "" {
+		return err
+	}
+	if _, removeErr := reap(context.Background(), localstack.Filter{ID: id}); removeErr != nil {
+		return errors.Join(err, removeErr)
+	}
+	_, _ = fmt.Fprintf(stderr, "golocalstack: removed %s, which didn't become ready\n", shortId(id))
+	return err
+}
+
+func down(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("down", stderr)
+	filter := filterFlags(flags)
+	all := flags.Bool("all", false, "remove all containers created by go-localstack")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if filter.ID == "" && len(filter.Labels) == 0 && !*all {
+		return errors.New("select the containers by -id or -label, or use -all")
+	}
+	removed, err := localstack.Reap(ctx, *filter)
+	for _, id := range removed {
+		_, _ = fmt.Fprintln(stdout, shortId(id))
+	}
+	return err
+}
+
+func status(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("status", stderr)
+	filter := filterFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	containers, err := localstack.List(ctx, *filter)
+	if err != nil {
+		return err
+	}
+	return printStatus(stdout, containers)
+}
+
+func env(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("env", stderr)
+	filter := filterFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	containers, err := localstack.List(ctx, *filter)
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(containers)
+	if err != nil {
+		return err
+	}
+	return printEnv(stdout, c.Endpoint)
+}
+
+func cleanup(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("cleanup", stderr)
+	opts := localstack.CleanupOptions{Filter: *filterFlags(flags)}
+	flags.DurationVar(&opts.OlderThan, "older-than", 0, "only remove containers and images created at least this long ago, e.g. 168h")
+	flags.BoolVar(&opts.Running, "running", false, "remove running containers as well")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "print what would be removed without removing it")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	report, err := localstack.Cleanup(ctx, opts)
+	if printErr := printCleanup(stdout, report, opts.DryRun); printErr != nil && err == nil {
+		err = printErr
+	}
+	return err
+}
+
+func doctor(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("doctor", stderr)
+	version := flags.String("version", localstack.LatestVersion, "version of localstack")
+	image := flags.String("image", "", "repository of the localstack image (default \"localstack/localstack\")")
+	authToken := flags.String("auth-token", os.Getenv("LOCALSTACK_AUTH_TOKEN"), "auth token of localstack (default $LOCALSTACK_AUTH_TOKEN)")
+	dockerEnv := flags.Bool("docker-env", false, "connect to docker like WithClientFromEnv, respecting DOCKER_HOST")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	opts := []localstack.InstanceOption{
+		localstack.WithVersion(*version),
+		localstack.WithAuthToken(*authToken),
+	}
+	if *image != "" {
+		opts = append(opts, localstack.WithImage(*image))
+	}
+	if *dockerEnv {
+		opt, err := localstack.WithClientFromEnvCtx(ctx)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, opt)
+	}
+	report := localstack.Preflight(ctx, opts...)
+	if err := printReport(stdout, report); err != nil {
+		return err
+	}
+	if !report.Passed() {
+		return errors.New("some checks failed")
+	}
+	return nil
+}
+
+// errUsage reports invalid flags, which were already printed with the usage of the command.
+var errUsage = errors.New("invalid usage")
+
+func newFlagSet(command string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// parseFlags parses the arguments of a command.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+	return nil
+}
+
+// filterFlags registers the flags for selecting containers.
+func filterFlags(flags *flag.FlagSet) *localstack.Filter {
+	filter := &localstack.Filter{Labels: labelFlag{}}
+	flags.StringVar(&filter.ID, "id", "", "ID of the container")
+	flags.Var(labelFlag(filter.Labels), "label", "label of the container as key=value, can be repeated")
+	return filter
+}
+
+// labelFlag collects repeated key=value flags.
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("%q is not key=value", value)
+	}
+	l[k] = v
+	return nil
+}
+
+// parseServices looks up the comma separated services by their names.
+func parseServices(names string) ([]localstack.Service, error) {
+	var services []localstack.Service
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		service, ok := lookupService(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown service %q", name)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+func lookupService(name string) (localstack.Service, bool) {
+	for service := range localstack.AvailableServices {
+		if service != localstack.FixedPort && strings.EqualFold(service.Name, name) {
+			return service, true
+		}
+	}
+	return localstack.Service{}, false
+}
+
+// printEndpoints prints the endpoint of each service or of all services, when none were selected.
+func printEndpoints(w io.Writer, i *localstack.Instance, services []localstack.Service) error {
+	if len(services) == 0 {
+		services = []localstack.Service{localstack.FixedPort}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, service := range services {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", service.Name, i.EndpointV2(service))
+	}
+	return tw.Flush()
+}
+
+func printStatus(w io.Writer, containers []localstack.Container) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tIMAGE\tSTATE\tCREATED\tENDPOINT\tLABELS")
+	for _, c := range containers {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			shortId(c.ID), c.Image, c.State, c.Created.Format(time.DateTime), c.Endpoint, userLabels(c.Labels))
+	}
+	return tw.Flush()
+}
+
+// userLabels formats the labels, which weren't set by go-localstack.
+func userLabels(labels map[string]string) string {
+	user := labelFlag{}
+	for k, v := range labels {
+		if !strings.HasPrefix(k, "go-localstack.") {
+			user[k] = v
+		}
+	}
+	return user.String()
+}
+
+// selectContainer returns the only running container with an endpoint.
+func selectContainer(containers []localstack.Container) (localstack.Container, error) {
+	var running []localstack.Container
+	for _, c := range containers {
+		if c.State == "running" && c.Endpoint != "" {
+			running = append(running, c)
+		}
+	}
+	switch len(running) {
+	case 0:
+		return localstack.Container{}, errors.New("no running localstack found, start one by 'golocalstack up'")
+	case 1:
+		return running[0], nil
+	default:
+		return localstack.Container{}, fmt.Errorf("%d running localstack containers found, select one by -id or -label", len(running))
+	}
+}
+
+// printEnv prints the environment for the AWS CLI and SDKs as shell exports.
+func printEnv(w io.Writer, endpoint string) error {
+	_, err := fmt.Fprintf(w, `export AWS_ENDPOINT_URL=%s
+export AWS_REGION=%s
+export AWS_DEFAULT_REGION=%s
+export AWS_ACCESS_KEY_ID=dummy
+export AWS_SECRET_ACCESS_KEY=dummy
+`, endpoint, region, region)
+	return err
+}
+
+// printCleanup prints the containers and images, which were removed by cleanup.
+func printCleanup(w io.Writer, report localstack.CleanupReport, dryRun bool) error {
+	action := "removed"
+	if dryRun {
+		action = "would remove"
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range report.Containers {
+		_, _ = fmt.Fprintf(tw, "%s container\t%s\t%s\t%s\n", action, shortId(c.ID), c.Image, c.Created.Format(time.DateTime))
+	}
+	for _, img := range report.Images {
+		_, _ = fmt.Fprintf(tw, "%s image\t%s\t%s\t%s\n", action, shortId(img.ID), strings.Join(img.Tags, ","), img.Created.Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+// printReport prints the result of each check and the hints for fixing the failed ones.
+func printReport(w io.Writer, report localstack.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range report.Checks {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Status, c.Name, c.Message)
+		if c.Hint != "" {
+			_, _ = fmt.Fprintf(tw, "\t\thint: %s\n", c.Hint)
+		}
+	}
+	return tw.Flush()
+}
+
+func shortId(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,274 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elgohr/go-localstack"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when         string
+		args         []string
+		expectCode   int
+		expectStdout string
+		expectStderr string
+	}{
+		{
+			when:         "no command is given",
+			expectCode:   2,
+			expectStderr: usage,
+		},
+		{
+			when:         "help is requested",
+			args:         []string{"help"},
+			expectStdout: usage,
+		},
+		{
+			when:         "the command is unknown",
+			args:         []string{"start"},
+			expectCode:   2,
+			expectStderr: "golocalstack: unknown command \"start\"\n\n" + usage,
+		},
+		{
+			when:         "down doesn't select containers",
+			args:         []string{"down"},
+			expectCode:   1,
+			expectStderr: "golocalstack: select the containers by -id or -label, or use -all\n",
+		},
+		{
+			when:         "arguments are left over",
+			args:         []string{"status", "-id", "abc", "def"},
+			expectCode:   1,
+			expectStderr: "golocalstack: unexpected arguments [\"def\"]\n",
+		},
+		{
+			when:         "up is given an unknown service",
+			args:         []string{"up", "-services", "s3,unknown"},
+			expectCode:   1,
+			expectStderr: "golocalstack: unknown service \"unknown\"\n",
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			require.Equal(t, s.expectCode, run(context.Background(), s.args, stdout, stderr))
+			require.Equal(t, s.expectStdout, stdout.String())
+			require.Equal(t, s.expectStderr, stderr.String())
+		})
+	}
+}
+
+func TestRun_InvalidFlag(t *testing.T) {
+	t.Parallel()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	require.Equal(t, 2, run(context.Background(), []string{"up", "-label", "team"}, stdout, stderr))
+	require.Contains(t, stderr.String(), `invalid value "team" for flag -label: "team" is not key=value`)
+}
+
+func TestRun_FlagHelp(t *testing.T) {
+	t.Parallel()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	require.Equal(t, 0, run(context.Background(), []string{"env", "-h"}, stdout, stderr))
+	require.Contains(t, stderr.String(), "Usage of env:")
+}
+
+func TestParseServices(t *testing.T) {
+	t.Parallel()
+	services, err := parseServices("s3, dynamodb,SQS,")
+	require.NoError(t, err)
+	require.Equal(t, []localstack.Service{localstack.S3, localstack.DynamoDB, localstack.SQS}, services)
+
+	services, err = parseServices("")
+	require.NoError(t, err)
+	require.Empty(t, services)
+
+	_, err = parseServices("all")
+	require.EqualError(t, err, `unknown service "all"`)
+}
+
+func TestLabelFlag(t *testing.T) {
+	t.Parallel()
+	labels := labelFlag{}
+	require.NoError(t, labels.Set("team=a"))
+	require.NoError(t, labels.Set("empty="))
+	require.NoError(t, labels.Set("url=http://host?a=b"))
+	require.EqualError(t, labels.Set("=a"), `"=a" is not key=value`)
+	require.Equal(t, labelFlag{"team": "a", "empty": "", "url": "http://host?a=b"}, labels)
+	require.Equal(t, "empty=,team=a,url=http://host?a=b", labels.String())
+}
+
+func TestPrintStatus(t *testing.T) {
+	t.Parallel()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	b := &bytes.Buffer{}
+	require.NoError(t, printStatus(b, []localstack.Container{
+		{
+			ID:       "0123456789abcdef",
+			Image:    "localstack/localstack:latest",
+			State:    "running",
+			Created:  created,
+			Labels:   map[string]string{"team": "a", "go-localstack.managed": "true"},
+			Endpoint: "http://localhost:32001",
+		},
+		{
+			ID:      "fedcba9876543210",
+			Image:   "localstack/localstack:3.0.0",
+			State:   "exited",
+			Created: created,
+		},
+	}))
+	require.Equal(t, `ID            IMAGE                         STATE    CREATED              ENDPOINT                LABELS
+0123456789ab  localstack/localstack:latest  running  2024-01-02 03:04:05  http://localhost:32001  team=a
+fedcba987654  localstack/localstack:3.0.0   exited   2024-01-02 03:04:05                          
+`, b.String())
+}
+
+func TestSelectContainer(t *testing.T) {
+	t.Parallel()
+	running := localstack.Container{ID: "running", State: "running", Endpoint: "http://localhost:32001"}
+	exited := localstack.Container{ID: "exited", State: "exited"}
+	legacy := localstack.Container{ID: "legacy", State: "running"}
+
+	c, err := selectContainer([]localstack.Container{exited, running, legacy})
+	require.NoError(t, err)
+	require.Equal(t, running, c)
+
+	_, err = selectContainer([]localstack.Container{exited, legacy})
+	require.EqualError(t, err, "no running localstack found, start one by 'golocalstack up'")
+
+	_, err = selectContainer([]localstack.Container{running, running})
+	require.EqualError(t, err, "2 running localstack containers found, select one by -id or -label")
+}
+
+func TestPrintEnv(t *testing.T) {
+	t.Parallel()
+	b := &bytes.Buffer{}
+	require.NoError(t, printEnv(b, "http://localhost:32001"))
+	require.Equal(t, `export AWS_ENDPOINT_URL=http://localhost:32001
+export AWS_REGION=us-east-1
+export AWS_DEFAULT_REGION=us-east-1
+export AWS_ACCESS_KEY_ID=dummy
+export AWS_SECRET_ACCESS_KEY=dummy
+`, b.String())
+}
+
+func TestPrintCleanup(t *testing.T) {
+	t.Parallel()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	report := localstack.CleanupReport{
+		Containers: []localstack.Container{{ID: "0123456789abcdef", Image: "localstack/localstack:latest", Created: created}},
+		Images:     []localstack.Image{{ID: "sha256:fedcba9876543210", Tags: []string{"go-localstack:latest"}, Created: created}},
+	}
+	for _, scenario := range [...]struct {
+		when   string
+		dryRun bool
+		expect string
+	}{
+		{
+			when: "containers and images were removed",
+			expect: `removed container  0123456789ab  localstack/localstack:latest  2024-01-02 03:04:05
+removed image      fedcba987654  go-localstack:latest          2024-01-02 03:04:05
+`,
+		},
+		{
+			when:   "containers and images would be removed",
+			dryRun: true,
+			expect: `would remove container  0123456789ab  localstack/localstack:latest  2024-01-02 03:04:05
+would remove image      fedcba987654  go-localstack:latest          2024-01-02 03:04:05
+`,
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			b := &bytes.Buffer{}
+			require.NoError(t, printCleanup(b, report, s.dryRun))
+			require.Equal(t, s.expect, b.String())
+		})
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	t.Parallel()
+	b := &bytes.Buffer{}
+	require.NoError(t, printReport(b, localstack.Report{Checks: []localstack.Check{
+		{Name: "docker client", Status: localstack.CheckPassed, Message: "connecting to unix:///var/run/docker.sock"},
+		{Name: "docker daemon", Status: localstack.CheckFailed, Message: "unix:///var/run/docker.sock is not reachable", Hint: "start docker"},
+		{Name: "image", Status: localstack.CheckSkipped, Message: "docker is not reachable"},
+	}}))
+	require.Equal(t, `pass  docker client  connecting to unix:///var/run/docker.sock
+fail  docker daemon  unix:///var/run/docker.sock is not reachable
+                     hint: start docker
+skip  image          docker is not reachable
+`, b.String())
+}
+
+func TestRemoveUnready(t *testing.T) {
+	t.Parallel()
+	startErr := errors.New("context canceled")
+	for _, scenario := range [...]struct {
+		when         string
+		id           string
+		reapErr      error
+		expectReaped []localstack.Filter
+		expectErr    string
+		expectStderr string
+	}{
+		{
+			when:      "no container was created",
+			expectErr: "context canceled",
+		},
+		{
+			when:         "a container was created",
+			id:           "0123456789abcdef",
+			expectReaped: []localstack.Filter{{ID: "0123456789abcdef"}},
+			expectErr:    "context canceled",
+			expectStderr: "golocalstack: removed 0123456789ab, which didn't become ready\n",
+		},
+		{
+			when:         "the container can't be removed",
+			id:           "0123456789abcdef",
+			reapErr:      errors.New("can't remove"),
+			expectReaped: []localstack.Filter{{ID: "0123456789abcdef"}},
+			expectErr:    "context canceled\ncan't remove",
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			var reaped []localstack.Filter
+			reap := func(_ context.Context, filter localstack.Filter) ([]string, error) {
+				reaped = append(reaped, filter)
+				return nil, s.reapErr
+			}
+			stderr := &bytes.Buffer{}
+			err := removeUnready(stderr, reap, s.id, startErr)
+			require.EqualError(t, err, s.expectErr)
+			require.ErrorIs(t, err, startErr)
+			require.Equal(t, s.expectReaped, reaped)
+			require.Equal(t, s.expectStderr, stderr.String())
+		})
+	}
+}
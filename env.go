@@ -0,0 +1,106 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// managedEnv are the environment variables set by go-localstack and the ways for configuring them.
+// The variables of the gateway would move the ports, which go-localstack maps and checks.
+var managedEnv = map[string]string{
+	"LOCALSTACK_AUTH_TOKEN": "NewAuthenticatedInstance or WithAuthToken",
+	"SERVICES":              "Start",
+	"PERSISTENCE":           "WithPersistence",
+	"GATEWAY_LISTEN":        "the endpoints of the instance",
+	"EDGE_PORT":             "the endpoints of the instance",
+	"EDGE_PORT_HTTP":        "the endpoints of the instance",
+	"EDGE_BIND_HOST":        "the endpoints of the instance",
+	"USE_SSL":               "the endpoints of the instance",
+}
+
+// WithEnv configures additional environment variables for localstack.
+// See https://docs.localstack.cloud/references/configuration/ for the available configuration.
+// Variables managed by go-localstack (like SERVICES) are rejected when creating the instance.
+func WithEnv(env map[string]string) InstanceOption {
+	return func(i *Instance) {
+		if i.env == nil {
+			i.env = map[string]string{}
+		}
+		maps.Copy(i.env, env)
+	}
+}
+
+// WithLocalstackConfig configures common localstack configuration.
+// It's merged with the variables of WithEnv.
+func WithLocalstackConfig(config LocalstackConfig) InstanceOption {
+	return WithEnv(config.env())
+}
+
+// LocalstackConfig contains common localstack configuration.
+// Zero values keep the defaults of localstack.
+type LocalstackConfig struct {
+	Debug               bool   // DEBUG
+	LogLevel            string // LS_LOG
+	DynamoDBShareDB     bool   // DYNAMODB_SHARE_DB
+	SQSEndpointStrategy string // SQS_ENDPOINT_STRATEGY
+	LambdaDockerNetwork string // LAMBDA_DOCKER_NETWORK
+	EagerServiceLoading bool   // EAGER_SERVICE_LOADING
+	EnableConfigUpdates bool   // ENABLE_CONFIG_UPDATES
+}
+
+func (c LocalstackConfig) env() map[string]string {
+	env := map[string]string{}
+	for key, enabled := range map[string]bool{
+		"DEBUG":                 c.Debug,
+		"DYNAMODB_SHARE_DB":     c.DynamoDBShareDB,
+		"EAGER_SERVICE_LOADING": c.EagerServiceLoading,
+		"ENABLE_CONFIG_UPDATES": c.EnableConfigUpdates,
+	} {
+		if enabled {
+			env[key] = "1"
+		}
+	}
+	for key, value := range map[string]string{
+		"LS_LOG":                c.LogLevel,
+		"SQS_ENDPOINT_STRATEGY": c.SQSEndpointStrategy,
+		"LAMBDA_DOCKER_NETWORK": c.LambdaDockerNetwork,
+	} {
+		if value != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+func validateEnv(env map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		if alternative, managed := managedEnv[key]; managed {
+			return fmt.Errorf("localstack: environment variable %q is managed by go-localstack, use %s instead", key, alternative)
+		}
+	}
+	return nil
+}
+
+// envList returns the variables in a stable order, as expected by Docker.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		list = append(list, key+"="+env[key])
+	}
+	return list
+}
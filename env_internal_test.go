@@ -0,0 +1,85 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_startLocalstack_Env(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerCreateReturns(container.CreateResponse{}, errors.New("can't create"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), authToken: "token"}
+	WithEnv(map[string]string{"LS_LOG": "warn", "DEBUG": "0"})(i)
+	WithLocalstackConfig(LocalstackConfig{Debug: true, SQSEndpointStrategy: "path"})(i)
+
+	require.EqualError(t, i.startLocalstack(context.Background(), SQS), "localstack: could not create container: can't create")
+
+	_, config, _, _, _, _ := f.ContainerCreateArgsForCall(0)
+	require.Equal(t, []string{
+		"LOCALSTACK_AUTH_TOKEN=token",
+		"SERVICES=dynamodb,sqs",
+		"DEBUG=1",
+		"LS_LOG=warn",
+		"SQS_ENDPOINT_STRATEGY=path",
+	}, config.Env)
+}
+
+func TestNewInstance_Fails_ManagedEnv(t *testing.T) {
+	t.Parallel()
+	for key, expect := range map[string]string{
+		"SERVICES":              `localstack: environment variable "SERVICES" is managed by go-localstack, use Start instead`,
+		"PERSISTENCE":           `localstack: environment variable "PERSISTENCE" is managed by go-localstack, use WithPersistence instead`,
+		"LOCALSTACK_AUTH_TOKEN": `localstack: environment variable "LOCALSTACK_AUTH_TOKEN" is managed by go-localstack, use NewAuthenticatedInstance or WithAuthToken instead`,
+		"GATEWAY_LISTEN":        `localstack: environment variable "GATEWAY_LISTEN" is managed by go-localstack, use the endpoints of the instance instead`,
+		"EDGE_PORT":             `localstack: environment variable "EDGE_PORT" is managed by go-localstack, use the endpoints of the instance instead`,
+		"EDGE_PORT_HTTP":        `localstack: environment variable "EDGE_PORT_HTTP" is managed by go-localstack, use the endpoints of the instance instead`,
+		"EDGE_BIND_HOST":        `localstack: environment variable "EDGE_BIND_HOST" is managed by go-localstack, use the endpoints of the instance instead`,
+		"USE_SSL":               `localstack: environment variable "USE_SSL" is managed by go-localstack, use the endpoints of the instance instead`,
+	} {
+		_, err := NewAuthenticatedInstance("token", WithEnv(map[string]string{key: "value"}))
+		require.EqualError(t, err, expect)
+	}
+}
+
+func TestLocalstackConfig_env(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, LocalstackConfig{}.env())
+	require.Equal(t, map[string]string{
+		"DEBUG":                 "1",
+		"LS_LOG":                "trace",
+		"DYNAMODB_SHARE_DB":     "1",
+		"SQS_ENDPOINT_STRATEGY": "domain",
+		"LAMBDA_DOCKER_NETWORK": "tests",
+		"EAGER_SERVICE_LOADING": "1",
+		"ENABLE_CONFIG_UPDATES": "1",
+	}, LocalstackConfig{
+		Debug:               true,
+		LogLevel:            "trace",
+		DynamoDBShareDB:     true,
+		SQSEndpointStrategy: "domain",
+		LambdaDockerNetwork: "tests",
+		EagerServiceLoading: true,
+		EnableConfigUpdates: true,
+	}.env())
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"fmt"
+)
+
+// checkHealth returns nil, when every requested service is available or running.
+func (i *Instance) checkHealth(ctx context.Context) error {
+	h, err := i.Admin().Health(ctx)
+	if err != nil {
+		return err
+	}
+	for _, service := range i.getServices() {
+		if !shouldBeAdded(service) {
+			continue
+		}
+		name := service.localstackName()
+		switch h.Services[name] {
+		case "available", "running":
+		default:
+			return fmt.Errorf("localstack: %s is %q", name, h.Services[name])
+		}
+	}
+	return nil
+}
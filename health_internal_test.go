@@ -0,0 +1,182 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_checkHealth(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when     string
+		services []Service
+		expect   string
+	}{
+		{
+			when: "starting all services",
+		},
+		{
+			when:     "the requested services are available or running",
+			services: []Service{S3, CloudWatchLogs, FixedPort},
+		},
+		{
+			when:     "a requested service isn't available yet",
+			services: []Service{S3, SQS},
+			expect:   `localstack: sqs is "initialized"`,
+		},
+		{
+			when:     "a requested service is unknown",
+			services: []Service{SNS},
+			expect:   `localstack: sns is ""`,
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/_localstack/health", r.URL.Path)
+				_, _ = w.Write([]byte(`{"services": {"s3": "running", "logs": "available", "sqs": "initialized"}}`))
+			}))
+			t.Cleanup(server.Close)
+			i := runningInstance(server)
+			i.setServices(s.services)
+
+			err := i.checkHealth(context.Background())
+			if s.expect == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, s.expect)
+			}
+		})
+	}
+}
+
+func TestInstance_checkHealth_Fails(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	require.EqualError(t, runningInstance(server).checkHealth(context.Background()),
+		"/_localstack/health 503 Service Unavailable")
+}
+
+func TestNewInstance_HealthEndpoint(t *testing.T) {
+	t.Parallel()
+	for version, expect := range map[string]bool{
+		LatestVersion:         true,
+		"1.0.0":               true,
+		"4.0.0":               true,
+		BreakingChangeVersion: false,
+		"0.14.5":              false,
+		"0.11.0":              false,
+	} {
+		i, err := NewAuthenticatedInstance("token", WithVersion(version))
+		require.NoError(t, err)
+		require.Equal(t, expect, i.hasHealthEndpoint(), version)
+	}
+}
+
+func TestInstance_checkReadiness_WithoutHealthEndpoint(t *testing.T) {
+	t.Parallel()
+	var paths []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path+" "+r.Header.Get("X-Amz-Target"))
+		mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	i := runningInstance(server)
+	i.legacyHealth = true
+
+	require.NoError(t, i.checkReadiness(context.Background()))
+	require.Equal(t, []string{
+		"/ DynamoDB_20120810.CreateTable",
+		"/ DynamoDB_20120810.DeleteTable",
+	}, paths)
+}
+
+func TestInstance_serviceNames(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when         string
+		fixedPort    bool
+		legacyHealth bool
+		services     []Service
+		expect       []string
+	}{
+		{
+			when:      "starting all services",
+			fixedPort: true,
+		},
+		{
+			when:      "starting services behind the fixed port",
+			fixedPort: true,
+			services:  []Service{FixedPort, SQS, CloudWatchLogs, ES},
+			expect:    []string{"sqs", "logs"},
+		},
+		{
+			when:      "starting dynamodb behind the fixed port",
+			fixedPort: true,
+			services:  []Service{DynamoDB},
+			expect:    []string{"dynamodb"},
+		},
+		{
+			when:     "starting services with a version without the fixed port",
+			services: []Service{SQS, DynamoDB},
+			expect:   []string{"dynamodb", "sqs"},
+		},
+		{
+			when:         "starting services with a fixed port version before the health endpoint",
+			fixedPort:    true,
+			legacyHealth: true,
+			services:     []Service{SQS, DynamoDB},
+			expect:       []string{"dynamodb", "sqs"},
+		},
+		{
+			when:     "starting only dynamodb with a version without the fixed port",
+			services: []Service{DynamoDB},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, s.expect, (&Instance{fixedPort: s.fixedPort, legacyHealth: s.legacyHealth}).serviceNames(s.services))
+		})
+	}
+}
+
+func TestInstance_Admin(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/_localstack/info", r.URL.Path)
+		_, _ = w.Write([]byte(`{"version": "4.0.0", "edition": "community"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	info, err := runningInstance(server).Admin().Info(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "4.0.0", info.Version)
+	require.Equal(t, "community", info.Edition)
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"fmt"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// labelImage names the image reference a container was created from,
+// as the container itself only refers to the ID of the image.
+const labelImage = "go-localstack.image"
+
+// defaultImage is the repository of the community image of localstack.
+const defaultImage = "localstack/localstack"
+
+// WithImage configures the repository of the localstack image,
+// e.g. localstack/localstack-pro or a registry mirror like mirror.example.com/localstack/localstack.
+// The repository must not contain a tag, as it is set by WithVersion.
+// The default is localstack/localstack.
+func WithImage(repository string) InstanceOption {
+	return func(i *Instance) {
+		i.image = repository
+	}
+}
+
+// WithRegistryAuth configures the credentials for pulling the image from a private registry.
+func WithRegistryAuth(auth registry.AuthConfig) InstanceOption {
+	return func(i *Instance) {
+		i.registryAuth = &auth
+	}
+}
+
+// validateImage ensures that the image is a repository without tag or digest.
+func validateImage(image string) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Errorf("localstack: invalid image %q specified: %w", image, err)
+	}
+	if !reference.IsNameOnly(named) {
+		return fmt.Errorf("localstack: invalid image %q specified, the tag is set by WithVersion", image)
+	}
+	return nil
+}
+
+// imageRef returns the reference of the localstack image.
+func (i *Instance) imageRef() string {
+	return i.image + ":" + i.version
+}
+
+// ensureImage pulls the image according to the pull policy and returns its ID.
+// Containers are created from the ID, so that concurrent pulls of the same tag (like latest)
+// can't change the image between resolving and creating.
+func (i *Instance) ensureImage(ctx context.Context) (string, error) {
+	if i.pullPolicy != PullAlways {
+		inspect, err := i.cli.ImageInspect(ctx, i.imageRef())
+		if err == nil {
+			return inspect.ID, nil
+		}
+		if !cerrdefs.IsNotFound(err) {
+			return "", fmt.Errorf("could not inspect image: %w", err)
+		}
+		if i.imageArchive != "" {
+			loaded, err := i.loadImage(ctx)
+			if err != nil {
+				return "", err
+			}
+			if loaded {
+				return i.imageId(ctx)
+			}
+		}
+		if i.pullPolicy == PullNever {
+			return "", fmt.Errorf("%s is not available locally and pulling is disabled", i.imageRef())
+		}
+	}
+	if err := i.pullImage(ctx); err != nil {
+		return "", fmt.Errorf("could not pull image: %w", err)
+	}
+	return i.imageId(ctx)
+}
+
+func (i *Instance) imageId(ctx context.Context) (string, error) {
+	inspect, err := i.cli.ImageInspect(ctx, i.imageRef())
+	if err != nil {
+		return "", fmt.Errorf("could not inspect image: %w", err)
+	}
+	return inspect.ID, nil
+}
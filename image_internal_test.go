@@ -0,0 +1,84 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_ensureImage(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturnsOnCall(0, image.InspectResponse{}, cerrdefs.ErrNotFound)
+	f.ImageInspectReturnsOnCall(1, image.InspectResponse{ID: "sha256:pulled"}, nil)
+	f.ImagePullReturns(io.NopCloser(strings.NewReader(`{"status": "Pulling"}`)), nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+	WithImage("mirror.example.com/localstack/localstack-pro")(i)
+	WithRegistryAuth(registry.AuthConfig{Username: "user", Password: "secret"})(i)
+
+	imageId, err := i.ensureImage(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "sha256:pulled", imageId)
+
+	_, inspected, _ := f.ImageInspectArgsForCall(0)
+	require.Equal(t, "mirror.example.com/localstack/localstack-pro:4.0.0", inspected)
+	_, pulled, options := f.ImagePullArgsForCall(0)
+	require.Equal(t, "mirror.example.com/localstack/localstack-pro:4.0.0", pulled)
+	auth, err := registry.DecodeAuthConfig(options.RegistryAuth)
+	require.NoError(t, err)
+	require.Equal(t, &registry.AuthConfig{Username: "user", Password: "secret"}, auth)
+}
+
+func TestInstance_ensureImage_Present(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturns(image.InspectResponse{ID: "sha256:present"}, nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+
+	imageId, err := i.ensureImage(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "sha256:present", imageId)
+	require.Equal(t, 0, f.ImagePullCallCount())
+}
+
+func TestNewInstance_Image(t *testing.T) {
+	t.Parallel()
+	i, err := NewAuthenticatedInstance("token", WithImage("localstack/localstack-pro"), WithVersion("4.0.0"))
+	require.NoError(t, err)
+	require.Equal(t, "localstack/localstack-pro", i.image)
+	require.Equal(t, "4.0.0", i.version)
+	require.True(t, i.fixedPort)
+}
+
+func TestNewInstance_Fails_Image(t *testing.T) {
+	t.Parallel()
+	for image, expect := range map[string]string{
+		"localstack/localstack:4.0.0": `localstack: invalid image "localstack/localstack:4.0.0" specified, the tag is set by WithVersion`,
+		"Localstack":                  `localstack: invalid image "Localstack" specified: invalid reference format: repository name (library/Localstack) must be lowercase`,
+	} {
+		_, err := NewAuthenticatedInstance("token", WithImage(image))
+		require.EqualError(t, err, expect)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// initTarget is the directory containing the init hooks of localstack.
+const initTarget = "/etc/localstack/init"
+
+// initStages are the stages of localstack running init hooks.
+var initStages = []string{"boot", "start", "ready", "shutdown"}
+
+// WithInitScripts configures scripts that are run by localstack on its init stages.
+// The top-level directories of fsys name the stages (boot, start, ready and shutdown)
+// and contain the scripts of the stage, e.g. ready/seed.sh.
+// Start returns when all scripts of the ready stage completed and fails when any script failed.
+// It requires version 1.0.0 or later.
+// Reset doesn't run the scripts again, so that resources created by them are gone after it.
+// See https://docs.localstack.cloud/references/init-hooks/
+func WithInitScripts(fsys fs.FS) InstanceOption {
+	return func(i *Instance) {
+		i.initScripts = fsys
+	}
+}
+
+// initArchive packs the init scripts for being copied into the container.
+func initArchive(fsys fs.FS) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	if err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == "." {
+			return err
+		}
+		stage, _, _ := strings.Cut(path, "/")
+		if !slices.Contains(initStages, stage) {
+			return fmt.Errorf("unknown init stage %q", stage)
+		}
+		name := stage + ".d" + strings.TrimPrefix(path, stage)
+		if d.IsDir() {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     name + "/",
+				Mode:     0o755,
+			})
+		}
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0o755,
+			Size: int64(len(content)),
+		}); err != nil {
+			return err
+		}
+		_, err = tw.Write(content)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// waitForInitScripts waits until the scripts of the ready stage completed.
+func (i *Instance) waitForInitScripts(ctx context.Context) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			status, err := i.Admin().Init(ctx)
+			if err != nil {
+				i.log.Debug(err)
+				continue
+			}
+			var failed []error
+			for _, script := range status.Scripts {
+				if script.State == "ERROR" {
+					failed = append(failed, fmt.Errorf("localstack: init script %s failed in stage %s", script.Name, strings.ToLower(script.Stage)))
+				}
+			}
+			if len(failed) > 0 {
+				return errors.Join(failed...)
+			}
+			if status.Completed["READY"] {
+				return nil
+			}
+		}
+	}
+}
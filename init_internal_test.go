@@ -0,0 +1,174 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitArchive(t *testing.T) {
+	t.Parallel()
+	archive, err := initArchive(fstest.MapFS{
+		"boot/prepare.sh": {Data: []byte("#!/bin/bash")},
+		"ready/seed.sh":   {Data: []byte("#!/bin/bash\nawslocal s3 mb s3://bucket")},
+	})
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, int64(0o755), header.Mode)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+	require.Equal(t, map[string]string{
+		"boot.d/":           "",
+		"boot.d/prepare.sh": "#!/bin/bash",
+		"ready.d/":          "",
+		"ready.d/seed.sh":   "#!/bin/bash\nawslocal s3 mb s3://bucket",
+	}, files)
+}
+
+func TestInitArchive_Fails_UnknownStage(t *testing.T) {
+	t.Parallel()
+	_, err := initArchive(fstest.MapFS{"seed.sh": {Data: []byte("#!/bin/bash")}})
+	require.EqualError(t, err, `unknown init stage "seed.sh"`)
+}
+
+func TestNewInstance_Fails_InitScriptsWithoutInternalAPI(t *testing.T) {
+	t.Parallel()
+	scripts := fstest.MapFS{"ready/seed.sh": {Data: []byte("#!/bin/sh")}}
+	for _, version := range []string{"0.10.0", BreakingChangeVersion, "0.14.5"} {
+		_, err := NewAuthenticatedInstance("token", WithVersion(version), WithInitScripts(scripts))
+		require.EqualError(t, err, "localstack: init scripts require version 1.0.0 or later", version)
+	}
+	_, err := NewAuthenticatedInstance("token", WithVersion("1.0.0"), WithInitScripts(scripts))
+	require.NoError(t, err)
+}
+
+func TestInstance_startLocalstack_InitScripts(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerCreateReturns(container.CreateResponse{ID: "created"}, nil)
+	f.ContainerStartReturns(errors.New("can't start"))
+	i := &Instance{cli: f, log: logrus.StandardLogger()}
+	WithInitScripts(fstest.MapFS{"ready/seed.sh": {Data: []byte("#!/bin/bash")}})(i)
+
+	require.EqualError(t, i.startLocalstack(context.Background()), "localstack: could not start container: can't start")
+
+	require.Equal(t, 1, f.CopyToContainerCallCount())
+	_, containerId, target, content, _ := f.CopyToContainerArgsForCall(0)
+	require.Equal(t, "created", containerId)
+	require.Equal(t, "/etc/localstack/init", target)
+	require.NotNil(t, content)
+}
+
+func TestInstance_startLocalstack_InitScripts_Fails(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when   string
+		given  func(f *internalfakes.FakeDockerClient) *Instance
+		expect string
+	}{
+		{
+			when: "scripts can't be read",
+			given: func(f *internalfakes.FakeDockerClient) *Instance {
+				return &Instance{cli: f, log: logrus.StandardLogger(), initScripts: fstest.MapFS{"other/seed.sh": {}}}
+			},
+			expect: `localstack: could not read init scripts: unknown init stage "other"`,
+		},
+		{
+			when: "scripts can't be copied",
+			given: func(f *internalfakes.FakeDockerClient) *Instance {
+				f.CopyToContainerReturns(errors.New("can't copy"))
+				return &Instance{cli: f, log: logrus.StandardLogger(), initScripts: fstest.MapFS{"ready/seed.sh": {}}}
+			},
+			expect: "localstack: could not copy init scripts: can't copy",
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			require.EqualError(t, s.given(f).startLocalstack(context.Background()), s.expect)
+			require.Equal(t, 0, f.ContainerStartCallCount())
+		})
+	}
+}
+
+func TestInstance_waitForInitScripts(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when   string
+		status string
+		expect func(t require.TestingT, err error)
+	}{
+		{
+			when:   "ready scripts completed",
+			status: `{"completed": {"BOOT": true, "START": true, "READY": true, "SHUTDOWN": false}, "scripts": [{"stage": "READY", "name": "seed.sh", "state": "SUCCESSFUL"}]}`,
+			expect: func(t require.TestingT, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			when:   "a script failed",
+			status: `{"completed": {"BOOT": true, "START": true, "READY": true, "SHUTDOWN": false}, "scripts": [{"stage": "READY", "name": "seed.sh", "state": "ERROR"}]}`,
+			expect: func(t require.TestingT, err error) {
+				require.EqualError(t, err, "localstack: init script seed.sh failed in stage ready")
+			},
+		},
+		{
+			when:   "ready scripts are still running",
+			status: `{"completed": {"BOOT": true, "START": true, "READY": false, "SHUTDOWN": false}, "scripts": [{"stage": "READY", "name": "seed.sh", "state": "RUNNING"}]}`,
+			expect: func(t require.TestingT, err error) {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+			},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/_localstack/init", r.URL.Path)
+				_, _ = w.Write([]byte(s.status))
+			}))
+			t.Cleanup(server.Close)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			s.expect(t, runningInstance(server).waitForInitScripts(ctx))
+		})
+	}
+}
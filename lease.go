@@ -0,0 +1,141 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	// defaultLease is the lease of containers, which are owned by the process.
+	defaultLease = 30 * time.Second
+	// defaultReuseLease is the lease of reused and shared containers,
+	// which must survive until they are adopted by the next process.
+	defaultReuseLease = 5 * time.Minute
+	// minLease gives the heartbeat enough time to reach the container.
+	minLease = 3 * time.Second
+)
+
+// heartbeatFile is touched within the container for renewing its lease.
+const heartbeatFile = "/tmp/go-localstack.heartbeat"
+
+// watchdogScript runs localstack and kills it, when the heartbeat file
+// wasn't touched within the lease (in seconds). Signals are forwarded to localstack.
+const watchdogScript = `touch %[1]s
+docker-entrypoint.sh &
+pid=$!
+trap 'kill -TERM $pid' TERM INT
+(while sleep 1; do
+  if [ $(( $(date +%%s) - $(stat -c %%Y %[1]s) )) -gt %[2]d ]; then
+    kill -KILL $pid
+    exit
+  fi
+done) &
+watchdog=$!
+status=0
+while kill -0 $pid 2>/dev/null; do
+  wait $pid
+  status=$?
+done
+kill $watchdog 2>/dev/null
+exit $status`
+
+// WithLease configures how long the container survives without a heartbeat of the instance.
+// The instance renews the lease in the background between Start and Stop,
+// so that the container dies shortly after the process crashed or was killed.
+// The default lease is 30 seconds, or 5 minutes for reused and shared containers (see WithReuse and WithSharing),
+// which must survive until they are adopted by the next process.
+// A lease of 0 disables it, leaving only the timeout (see WithTimeout).
+func WithLease(lease time.Duration) InstanceOption {
+	return func(i *Instance) {
+		i.lease = lease
+		i.leaseConfigured = true
+	}
+}
+
+// validateLease ensures that the lease can be renewed in time.
+func validateLease(lease time.Duration) error {
+	if lease != 0 && lease < minLease {
+		return fmt.Errorf("localstack: lease must be at least %s", minLease)
+	}
+	return nil
+}
+
+// entrypoint runs localstack within the watchdog of the lease and the timeout, when configured.
+func (i *Instance) entrypoint() []string {
+	entrypoint := []string{"docker-entrypoint.sh"}
+	if i.lease > 0 {
+		entrypoint = []string{"sh", "-c", fmt.Sprintf(watchdogScript, heartbeatFile, int(i.lease.Seconds()))}
+	}
+	if i.timeout > 0 {
+		entrypoint = append([]string{"timeout", "-s", "SIGKILL", strconv.Itoa(int(i.timeout.Seconds()))}, entrypoint...)
+	}
+	return entrypoint
+}
+
+// keepAlive renews the lease of the container immediately and in the background until Stop.
+// The immediate renewal saves adopted containers, whose lease is about to expire.
+func (i *Instance) keepAlive(containerId string) {
+	if i.lease <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	i.containerIdMutex.Lock()
+	i.endHeartbeat()
+	i.stopHeartbeat = cancel
+	i.containerIdMutex.Unlock()
+
+	if err := i.renewLease(ctx, containerId); err != nil {
+		i.log.Warnf("localstack: could not renew lease: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(i.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := i.renewLease(ctx, containerId); err != nil && ctx.Err() == nil {
+					i.log.Warnf("localstack: could not renew lease: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// endHeartbeat stops renewing the lease. The caller must hold containerIdMutex.
+func (i *Instance) endHeartbeat() {
+	if i.stopHeartbeat != nil {
+		i.stopHeartbeat()
+		i.stopHeartbeat = nil
+	}
+}
+
+func (i *Instance) renewLease(ctx context.Context, containerId string) error {
+	exec, err := i.cli.ContainerExecCreate(ctx, containerId, container.ExecOptions{
+		Cmd: []string{"touch", heartbeatFile},
+	})
+	if err != nil {
+		return err
+	}
+	return i.cli.ContainerExecStart(ctx, exec.ID, container.ExecStartOptions{Detach: true})
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_entrypoint(t *testing.T) {
+	t.Parallel()
+	watchdog := []string{"sh", "-c", fmt.Sprintf(watchdogScript, heartbeatFile, 30)}
+	for _, scenario := range [...]struct {
+		when     string
+		instance *Instance
+		expect   []string
+	}{
+		{
+			when:     "neither lease nor timeout are configured",
+			instance: &Instance{},
+			expect:   []string{"docker-entrypoint.sh"},
+		},
+		{
+			when:     "a lease is configured",
+			instance: &Instance{lease: 30 * time.Second},
+			expect:   watchdog,
+		},
+		{
+			when:     "a timeout is configured",
+			instance: &Instance{timeout: time.Minute},
+			expect:   []string{"timeout", "-s", "SIGKILL", "60", "docker-entrypoint.sh"},
+		},
+		{
+			when:     "a lease and a timeout are configured",
+			instance: &Instance{lease: 30 * time.Second, timeout: time.Minute},
+			expect:   append([]string{"timeout", "-s", "SIGKILL", "60"}, watchdog...),
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, s.expect, s.instance.entrypoint())
+		})
+	}
+}
+
+func TestNewInstance_Lease(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when   string
+		opts   []InstanceOption
+		expect time.Duration
+	}{
+		{
+			when:   "using the default",
+			expect: defaultLease,
+		},
+		{
+			when:   "reusing the container",
+			opts:   []InstanceOption{WithReuse()},
+			expect: defaultReuseLease,
+		},
+		{
+			when:   "sharing the container",
+			opts:   []InstanceOption{WithSharing()},
+			expect: defaultReuseLease,
+		},
+		{
+			when:   "configuring the lease of a reused container",
+			opts:   []InstanceOption{WithReuse(), WithLease(time.Minute)},
+			expect: time.Minute,
+		},
+		{
+			when: "disabling the lease",
+			opts: []InstanceOption{WithLease(0)},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			i, err := NewAuthenticatedInstance("token", s.opts...)
+			require.NoError(t, err)
+			require.Equal(t, s.expect, i.lease)
+			require.Zero(t, i.timeout)
+		})
+	}
+}
+
+func TestNewInstance_Fails_Lease(t *testing.T) {
+	t.Parallel()
+	_, err := NewAuthenticatedInstance("token", WithLease(time.Second))
+	require.EqualError(t, err, "localstack: lease must be at least 3s")
+}
+
+func TestInstance_keepAlive(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerExecCreateReturns(container.ExecCreateResponse{ID: "exec"}, nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), lease: 30 * time.Millisecond, containerId: "running"}
+
+	i.keepAlive("running")
+
+	require.Eventually(t, func() bool {
+		return f.ContainerExecStartCallCount() >= 2
+	}, time.Second, 5*time.Millisecond)
+	_, containerId, options := f.ContainerExecCreateArgsForCall(0)
+	require.Equal(t, "running", containerId)
+	require.Equal(t, []string{"touch", heartbeatFile}, options.Cmd)
+	_, execId, startOptions := f.ContainerExecStartArgsForCall(0)
+	require.Equal(t, "exec", execId)
+	require.True(t, startOptions.Detach)
+
+	require.NoError(t, i.Stop())
+	renewals := f.ContainerExecCreateCallCount()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, renewals, f.ContainerExecCreateCallCount())
+}
+
+func TestInstance_keepAlive_Disabled(t *testing.T) {
+	t.Parallel()
+	i := &Instance{cli: &internalfakes.FakeDockerClient{}, log: logrus.StandardLogger()}
+	i.keepAlive("running")
+	require.Nil(t, i.stopHeartbeat)
+}
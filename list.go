@@ -0,0 +1,73 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/elgohr/go-localstack/internal"
+)
+
+// Container is a container created by go-localstack.
+type Container struct {
+	ID      string
+	Image   string
+	State   string
+	Created time.Time
+	Labels  map[string]string
+	// Endpoint is the address of the fixed port (see FixedPort) on the host, when it's published.
+	Endpoint string
+}
+
+// List returns the containers created by go-localstack that match the filter.
+func List(ctx context.Context, filter Filter) ([]Container, error) {
+	cli, err := newDockerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer logClose(cli)
+	return list(ctx, cli, filter)
+}
+
+func list(ctx context.Context, cli internal.DockerClient, filter Filter) ([]Container, error) {
+	summaries, err := listContainers(ctx, cli, filter)
+	if err != nil {
+		return nil, fmt.Errorf("localstack: could not list containers: %w", err)
+	}
+	containers := make([]Container, 0, len(summaries))
+	for _, s := range summaries {
+		c := Container{
+			ID:      s.ID,
+			Image:   s.Labels[labelImage],
+			State:   string(s.State),
+			Created: time.Unix(s.Created, 0),
+			Labels:  s.Labels,
+		}
+		if c.Image == "" {
+			c.Image = s.Image
+		}
+		for _, port := range s.Ports {
+			if strconv.Itoa(int(port.PrivatePort))+"/"+port.Type == FixedPort.Port && port.PublicPort != 0 {
+				c.Endpoint = "http://localhost:" + strconv.Itoa(int(port.PublicPort))
+				break
+			}
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
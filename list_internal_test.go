@@ -0,0 +1,87 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerListReturns([]container.Summary{
+		{
+			ID:      "first",
+			Image:   "sha256:123",
+			State:   container.StateRunning,
+			Created: 1700000000,
+			Labels:  map[string]string{labelManaged: "true", labelImage: "localstack/localstack:latest"},
+			Ports: []container.Port{
+				{PrivatePort: 4510, PublicPort: 32000, Type: "tcp"},
+				{PrivatePort: 4566, PublicPort: 32001, Type: "tcp"},
+			},
+		},
+		{
+			ID:      "second",
+			Image:   "sha256:456",
+			State:   container.StateExited,
+			Created: 1700000001,
+			Labels:  map[string]string{labelManaged: "true"},
+		},
+	}, nil)
+
+	containers, err := list(context.Background(), f, Filter{Labels: map[string]string{"team": "a"}})
+	require.NoError(t, err)
+	require.Equal(t, []Container{
+		{
+			ID:       "first",
+			Image:    "localstack/localstack:latest",
+			State:    container.StateRunning,
+			Created:  time.Unix(1700000000, 0),
+			Labels:   map[string]string{labelManaged: "true", labelImage: "localstack/localstack:latest"},
+			Endpoint: "http://localhost:32001",
+		},
+		{
+			ID:      "second",
+			Image:   "sha256:456",
+			State:   container.StateExited,
+			Created: time.Unix(1700000001, 0),
+			Labels:  map[string]string{labelManaged: "true"},
+		},
+	}, containers)
+
+	_, options := f.ContainerListArgsForCall(0)
+	require.True(t, options.All)
+	require.Equal(t, filters.NewArgs(
+		filters.Arg("label", labelManaged+"=true"),
+		filters.Arg("label", "team=a"),
+	), options.Filters)
+}
+
+func TestList_Fails(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerListReturns(nil, errors.New("can't list"))
+	_, err := list(context.Background(), f, Filter{})
+	require.EqualError(t, err, "localstack: could not list containers: can't list")
+}
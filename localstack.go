@@ -15,14 +15,16 @@
 package localstack
 
 import (
-	"archive/tar"
 	"bytes"
 	"context"
-	_ "embed"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,12 +34,15 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	dynamotypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/docker/docker/api/types/build"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"github.com/sirupsen/logrus"
 
+	"github.com/elgohr/go-localstack/admin"
 	"github.com/elgohr/go-localstack/internal"
 )
 
@@ -51,12 +56,42 @@ type Instance struct {
 
 	containerId      string
 	containerIdMutex sync.RWMutex
-
-	labels    map[string]string
-	authToken string
-	version   string
-	fixedPort bool
-	timeout   time.Duration
+	shareHash        string
+	stopHeartbeat    context.CancelFunc
+	services         []Service
+
+	labels       map[string]string
+	authToken    string
+	image        string
+	registryAuth *registry.AuthConfig
+	pullPolicy   PullPolicy
+	pullProgress func(PullEvent)
+	imageArchive string
+	version      string
+	fixedPort    bool
+	legacyHealth bool
+	timeout      time.Duration
+	lease        time.Duration
+	reuse        bool
+	shared       bool
+	reaper       bool
+
+	signalCleanup bool
+
+	leaseConfigured bool
+
+	env             map[string]string
+	persistenceDir  string
+	initScripts     fs.FS
+	readinessProbes []ReadinessProbe
+	seeds           *seedManifest
+	seedErr         error
+
+	readinessProbeTimeout time.Duration
+
+	network        string
+	networkAliases []string
+	createNetwork  bool
 }
 
 // InstanceOption is an option that controls the behaviour of
@@ -71,6 +106,13 @@ func WithVersion(version string) InstanceOption {
 	}
 }
 
+// WithAuthToken configures the localstack auth token of the instance.
+func WithAuthToken(authToken string) InstanceOption {
+	return func(i *Instance) {
+		i.authToken = authToken
+	}
+}
+
 // WithLogger configures the instance to use the specified logger.
 func WithLogger(logger *logrus.Logger) InstanceOption {
 	return func(i *Instance) {
@@ -85,15 +127,61 @@ func WithLabels(labels map[string]string) InstanceOption {
 	}
 }
 
-// WithTimeout configures the timeout for terminating the localstack instance.
-// This was invented to prevent orphaned containers after panics.
-// The default timeout is set to 5 minutes.
+// WithTimeout configures a hard limit for the lifetime of the localstack instance,
+// which terminates it even while the lease is renewed (see WithLease).
+// There is no limit by default.
 func WithTimeout(timeout time.Duration) InstanceOption {
 	return func(i *Instance) {
 		i.timeout = timeout
 	}
 }
 
+// WithReuse configures the instance to adopt an already running container
+// with the same configuration (version, services, labels and environment) instead
+// of creating a new one.
+// Stop leaves a reused container running, so that it can be adopted by the next run.
+// It is terminated, when it's not adopted within its lease (see WithLease).
+func WithReuse() InstanceOption {
+	return func(i *Instance) {
+		i.reuse = true
+	}
+}
+
+// WithSharing configures the instance to share one container with all processes
+// using the same configuration, like the packages of `go test ./...`.
+// The first process starts the container, later processes attach to it and
+// the last process calling Stop terminates it.
+func WithSharing() InstanceOption {
+	return func(i *Instance) {
+		i.shared = true
+	}
+}
+
+// WithPersistence configures the instance to keep its state in the given directory on the host.
+// The state survives restarts and can be committed as a test fixture.
+// Stop shuts down localstack gracefully, so that it can write its state.
+func WithPersistence(hostDir string) InstanceOption {
+	return func(i *Instance) {
+		i.persistenceDir = hostDir
+	}
+}
+
+// WithNetwork configures the instance to join the given Docker network with the given aliases.
+// Other containers in the network reach localstack by InternalEndpoint.
+func WithNetwork(name string, aliases ...string) InstanceOption {
+	return func(i *Instance) {
+		i.network = name
+		i.networkAliases = aliases
+	}
+}
+
+// WithNetworkCreation configures the instance to create the network of WithNetwork, when it doesn't exist.
+func WithNetworkCreation() InstanceOption {
+	return func(i *Instance) {
+		i.createNetwork = true
+	}
+}
+
 // WithClientFromEnv configures the instance to use a client that respects environment variables.
 func WithClientFromEnv() (InstanceOption, error) {
 	return WithClientFromEnvCtx(context.Background())
@@ -121,6 +209,12 @@ const (
 // Semver constraint that tests it the version is affected by the port change.
 var portChangeIntroduced = internal.MustParseConstraint(">= " + BreakingChangeVersion)
 
+// internalAPIVersion is the first version serving the internal API (/_localstack/*), like its health.
+const internalAPIVersion = "1.0.0"
+
+// Semver constraint that tests if the version serves the health of localstack by /_localstack/health.
+var healthEndpointIntroduced = internal.MustParseConstraint(">= " + internalAPIVersion)
+
 // NewAuthenticatedInstance creates a new Instance using a localstack auth token
 func NewAuthenticatedInstance(authToken string, opts ...InstanceOption) (*Instance, error) {
 	return NewAuthenticatedInstanceWithContext(context.Background(), authToken, opts...)
@@ -163,13 +257,20 @@ func NewInstanceCtx(ctx context.Context, opts ...InstanceOption) (*Instance, err
 }
 
 // Start starts the localstack
+// and returns when the given services (or localstack itself, when none are given) are available.
 func (i *Instance) Start(services ...Service) error {
 	return i.start(context.Background(), services...)
 }
 
+// StartCtx is Start, but with Context.
+// The context limits starting only, the instance keeps running until Stop.
+func (i *Instance) StartCtx(ctx context.Context, services ...Service) error {
+	return i.start(ctx, services...)
+}
+
 // StartWithContext starts the localstack and ends it when the context is done.
 //
-// Deprecated: Use Start/Stop instead, as shutdown is not reliable
+// Deprecated: Use Start/StartCtx and Stop instead, as shutdown is not reliable
 func (i *Instance) StartWithContext(ctx context.Context, services ...Service) error {
 	go func() {
 		<-ctx.Done()
@@ -185,6 +286,12 @@ func (i *Instance) Stop() error {
 	return i.stop()
 }
 
+// ContainerID returns the ID of the container, while the instance is running.
+// It's also set after Start failed, until Stop.
+func (i *Instance) ContainerID() string {
+	return i.getContainerId()
+}
+
 // Endpoint returns the endpoint for the given service
 // Endpoints are allocated dynamically (to avoid blocked ports), but are fix after starting the instance
 func (i *Instance) Endpoint(service Service) string {
@@ -209,6 +316,33 @@ func (i *Instance) EndpointV2(service Service) string {
 	return ""
 }
 
+// InternalEndpoint returns the endpoint for the given service within the network of WithNetwork
+// Endpoints are empty, when the instance isn't running or doesn't join a network
+func (i *Instance) InternalEndpoint(service Service) string {
+	containerId := i.getContainerId()
+	if containerId == "" || i.network == "" {
+		return ""
+	}
+	host := containerId
+	if len(host) > 12 {
+		host = host[:12]
+	}
+	if len(i.networkAliases) > 0 {
+		host = i.networkAliases[0]
+	}
+	port := service.Port
+	if i.fixedPort {
+		port = FixedPort.Port
+	}
+	return "http://" + host + ":" + strings.TrimSuffix(port, "/tcp")
+}
+
+// Admin returns a client for the internal API of localstack (/_localstack/*).
+// It's only available for versions with the fixed port (see FixedPort) and while the instance is running.
+func (i *Instance) Admin() *admin.Client {
+	return admin.New(i.EndpointV2(FixedPort), nil)
+}
+
 // Service represents an AWS service
 type Service struct {
 	Name string
@@ -270,25 +404,51 @@ var AvailableServices = map[Service]struct{}{
 	StepFunctions:    {},
 }
 
-func newInstanceCtx(ctx context.Context, opts ...InstanceOption) (*Instance, error) {
+// newDockerClient creates the default client of instances.
+func newDockerClient(ctx context.Context) (*client.Client, error) {
 	cli, err := client.NewClientWithOpts()
 	if err != nil {
-		return nil, fmt.Errorf("localstack: could not connect to docker: %w", err)
+		return nil, dockerUnreachable{err: fmt.Errorf("localstack: could not connect to docker: %w", err)}
 	}
 	cli.NegotiateAPIVersion(ctx)
+	return cli, nil
+}
+
+func newInstanceCtx(ctx context.Context, opts ...InstanceOption) (*Instance, error) {
+	cli, err := newDockerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	i := Instance{
 		cli:         cli,
 		log:         logrus.StandardLogger(),
+		image:       defaultImage,
 		version:     LatestVersion,
 		portMapping: map[Service]string{},
-		timeout:     5 * time.Minute,
+		lease:       defaultLease,
 	}
 
 	for _, opt := range opts {
 		opt(&i)
 	}
 
+	if (i.reuse || i.shared) && !i.leaseConfigured {
+		i.lease = defaultReuseLease
+	}
+	if err := validateLease(i.lease); err != nil {
+		return nil, err
+	}
+	if err := validateImage(i.image); err != nil {
+		return nil, err
+	}
+	if err := validateEnv(i.env); err != nil {
+		return nil, err
+	}
+	if i.seedErr != nil {
+		return nil, i.seedErr
+	}
+
 	if i.version == LatestVersion {
 		i.fixedPort = true
 	} else {
@@ -299,6 +459,10 @@ func newInstanceCtx(ctx context.Context, opts ...InstanceOption) (*Instance, err
 
 		i.version = version.String()
 		i.fixedPort = portChangeIntroduced.Check(version)
+		i.legacyHealth = !healthEndpointIntroduced.Check(version)
+	}
+	if i.initScripts != nil && !i.hasHealthEndpoint() {
+		return nil, fmt.Errorf("localstack: init scripts require version %s or later", internalAPIVersion)
 	}
 
 	return &i, nil
@@ -311,13 +475,32 @@ func (i *Instance) start(ctx context.Context, services ...Service) error {
 			return fmt.Errorf("localstack: can't stop an already running instance: %w", err)
 		}
 	}
-	return i.startContainer(ctx, services, 0)
+	if i.reaper {
+		removed, err := reap(ctx, i.cli, Filter{Orphaned: true})
+		if err != nil {
+			return err
+		}
+		if len(removed) > 0 {
+			i.log.Infof("removed %d orphaned containers", len(removed))
+		}
+	}
+	i.setServices(services)
+	if err := i.startContainer(ctx, services, 0); err != nil {
+		// the container dies with its lease
+		i.containerIdMutex.Lock()
+		i.endHeartbeat()
+		i.containerIdMutex.Unlock()
+		return err
+	}
+	return nil
 }
 
 func (i *Instance) startContainer(ctx context.Context, services []Service, try int) error {
 	if err := i.startLocalstack(ctx, services...); err != nil {
 		return err
 	}
+	i.keepAlive(i.getContainerId())
+	i.registerSignalCleanup()
 
 	i.log.Info("waiting for localstack to start...")
 	err := i.waitToBeAvailable(ctx)
@@ -328,16 +511,29 @@ func (i *Instance) startContainer(ctx context.Context, services []Service, try i
 		i.log.Debugln("missing container retrying")
 		return i.startContainer(ctx, services, try+1)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	if i.initScripts != nil {
+		i.log.Info("waiting for init scripts...")
+		if err := i.waitForInitScripts(ctx); err != nil {
+			return err
+		}
+	}
+	if i.seeds != nil {
+		i.log.Info("seeding localstack")
+		return i.seed(ctx, i.seeds)
+	}
+	return nil
 }
 
-const imageName = "go-localstack"
+// persistenceTarget is the directory containing the state of localstack.
+const persistenceTarget = "/var/lib/localstack"
 
-func (i *Instance) startLocalstack(ctx context.Context, services ...Service) error {
-	if err := i.buildLocalImage(ctx); err != nil {
-		return fmt.Errorf("localstack: could not build image: %w", err)
-	}
+// gracefulStopTimeout is the time in seconds localstack gets for writing its state on Stop.
+const gracefulStopTimeout = 30
 
+func (i *Instance) startLocalstack(ctx context.Context, services ...Service) error {
 	pm := nat.PortMap{}
 	for service := range AvailableServices {
 		pm[nat.Port(service.Port)] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: ""}}
@@ -347,32 +543,95 @@ func (i *Instance) startLocalstack(ctx context.Context, services ...Service) err
 	if i.authToken != "" {
 		environmentVariables = append(environmentVariables, "LOCALSTACK_AUTH_TOKEN="+i.authToken)
 	}
-	if len(services) > 0 {
-		startServices := "SERVICES=dynamodb" // for waitToBeAvailable
-		addedServices := 0
-		for _, service := range services {
-			if shouldBeAdded(service) {
-				startServices += "," + service.Name
-				addedServices++
-			}
+	if names := i.serviceNames(services); len(names) > 0 {
+		environmentVariables = append(environmentVariables, "SERVICES="+strings.Join(names, ","))
+	}
+
+	environmentVariables = append(environmentVariables, envList(i.env)...)
+
+	var mounts []mount.Mount
+	if i.persistenceDir != "" {
+		hostDir, err := filepath.Abs(i.persistenceDir)
+		if err != nil {
+			return fmt.Errorf("localstack: could not resolve persistence directory: %w", err)
 		}
-		if addedServices > 0 {
-			environmentVariables = append(environmentVariables, startServices)
+		if err := os.MkdirAll(hostDir, 0o750); err != nil {
+			return fmt.Errorf("localstack: could not create persistence directory: %w", err)
 		}
+		environmentVariables = append(environmentVariables, "PERSISTENCE=1")
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeBind,
+			Source: hostDir,
+			Target: persistenceTarget,
+		})
 	}
 
-	resp, err := i.cli.ContainerCreate(ctx,
-		&container.Config{
-			Image:        imageName,
+	spec := containerSpec{
+		Config: &container.Config{
+			Image:        i.imageRef(),
+			Entrypoint:   i.entrypoint(),
 			Env:          environmentVariables,
 			Labels:       i.labels,
 			Tty:          true,
 			AttachStdout: true,
 			AttachStderr: true,
-		}, &container.HostConfig{
+		},
+		HostConfig: &container.HostConfig{
 			PortBindings: pm,
+			Mounts:       mounts,
 			AutoRemove:   true,
-		}, nil, nil, "")
+		},
+	}
+	if i.initScripts != nil {
+		archive, err := initArchive(i.initScripts)
+		if err != nil {
+			return fmt.Errorf("localstack: could not read init scripts: %w", err)
+		}
+		spec.InitScripts = archive
+	}
+	if i.network != "" {
+		spec.NetworkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				i.network: {Aliases: i.networkAliases},
+			},
+		}
+	}
+
+	if !i.reuse && !i.shared {
+		return i.createContainer(ctx, services, spec)
+	}
+	hash, err := i.configHash(spec)
+	if err != nil {
+		return fmt.Errorf("localstack: could not hash configuration: %w", err)
+	}
+	if i.shared {
+		return i.shareContainer(ctx, services, hash, spec)
+	}
+	return i.reuseContainer(ctx, services, hash, spec)
+}
+
+// containerSpec contains everything needed for creating the container.
+type containerSpec struct {
+	Config           *container.Config
+	HostConfig       *container.HostConfig
+	NetworkingConfig *network.NetworkingConfig
+	InitScripts      []byte
+}
+
+func (i *Instance) createContainer(ctx context.Context, services []Service, spec containerSpec) error {
+	imageId, err := i.ensureImage(ctx)
+	if err != nil {
+		return fmt.Errorf("localstack: %w", err)
+	}
+	spec.Config.Labels = mergeLabels(spec.Config.Labels, i.ownerLabels(), map[string]string{labelImage: spec.Config.Image})
+	spec.Config.Image = imageId
+	if i.createNetwork {
+		if err := i.ensureNetwork(ctx); err != nil {
+			return fmt.Errorf("localstack: could not create network: %w", err)
+		}
+	}
+
+	resp, err := i.cli.ContainerCreate(ctx, spec.Config, spec.HostConfig, spec.NetworkingConfig, nil, "")
 	if err != nil {
 		return fmt.Errorf("localstack: could not create container: %w", err)
 	}
@@ -380,6 +639,12 @@ func (i *Instance) startLocalstack(ctx context.Context, services ...Service) err
 	containerId := resp.ID
 	i.setContainerId(containerId)
 
+	if len(spec.InitScripts) > 0 {
+		if err := i.cli.CopyToContainer(ctx, containerId, initTarget, bytes.NewReader(spec.InitScripts), container.CopyToContainerOptions{}); err != nil {
+			return fmt.Errorf("localstack: could not copy init scripts: %w", err)
+		}
+	}
+
 	i.log.Info("starting localstack")
 	if err := i.cli.ContainerStart(ctx, containerId, container.StartOptions{}); err != nil {
 		return fmt.Errorf("localstack: could not start container: %w", err)
@@ -392,44 +657,6 @@ func (i *Instance) startLocalstack(ctx context.Context, services ...Service) err
 	return i.mapPorts(ctx, services, containerId, 0)
 }
 
-//go:embed Dockerfile
-var dockerTemplate string
-
-func (i *Instance) buildLocalImage(ctx context.Context) error {
-	buf := &bytes.Buffer{}
-	tw := tar.NewWriter(buf)
-	defer logClose(tw)
-
-	dockerFile := "Dockerfile"
-	dockerFileContent := []byte(fmt.Sprintf(dockerTemplate, i.version, int(i.timeout.Seconds())))
-	if err := tw.WriteHeader(&tar.Header{
-		Name: dockerFile,
-		Size: int64(len(dockerFileContent)),
-	}); err != nil {
-		return err
-	}
-
-	if _, err := tw.Write(dockerFileContent); err != nil {
-		return err
-	}
-
-	dockerFileTarReader := bytes.NewReader(buf.Bytes())
-	imageBuildResponse, err := i.cli.ImageBuild(ctx, dockerFileTarReader, build.ImageBuildOptions{
-		Tags:           []string{imageName},
-		Dockerfile:     dockerFile,
-		SuppressOutput: true,
-		Remove:         true,
-		ForceRemove:    true,
-	})
-	if err != nil {
-		return err
-	}
-	defer logClose(imageBuildResponse.Body)
-
-	_, err = io.Copy(io.Discard, imageBuildResponse.Body)
-	return err
-}
-
 func (i *Instance) mapPorts(ctx context.Context, services []Service, containerId string, try int) error {
 	if try > 10 {
 		return errors.New("localstack: could not get port from container")
@@ -469,14 +696,42 @@ func (i *Instance) mapPorts(ctx context.Context, services []Service, containerId
 }
 
 func (i *Instance) stop() error {
+	i.unregisterSignalCleanup()
 	i.containerIdMutex.Lock()
 	defer i.containerIdMutex.Unlock()
+	i.endHeartbeat()
 	if i.containerId == "" {
 		return nil
 	}
-	if err := i.cli.ContainerStop(context.Background(), i.containerId, container.StopOptions{
+	if i.shared {
+		last, err := releaseShare(i.shareHash, i.containerId)
+		if err != nil {
+			return fmt.Errorf("localstack: could not release shared container: %w", err)
+		}
+		if !last {
+			i.log.Info("leaving localstack running for other processes")
+			i.containerId = ""
+			i.resetPortMapping()
+			return nil
+		}
+	}
+	if i.reuse {
+		i.log.Info("leaving localstack running for reuse")
+		i.containerId = ""
+		i.resetPortMapping()
+		return nil
+	}
+	stopOptions := container.StopOptions{
 		Signal: "SIGKILL",
-	}); err != nil {
+	}
+	if i.persistenceDir != "" {
+		timeout := gracefulStopTimeout
+		stopOptions = container.StopOptions{
+			Signal:  "SIGTERM",
+			Timeout: &timeout,
+		}
+	}
+	if err := i.cli.ContainerStop(context.Background(), i.containerId, stopOptions); err != nil {
 		return err
 	}
 	i.containerId = ""
@@ -495,9 +750,12 @@ func (i *Instance) waitToBeAvailable(ctx context.Context) error {
 			if err := i.isRunning(ctx); err != nil {
 				return containerMissing{err: err}
 			}
-			if err := i.checkAvailable(ctx); err != nil {
+			if err := i.checkReadiness(ctx); err != nil {
 				i.log.Debug(err)
 			} else {
+				if err := i.checkReadinessProbes(ctx); err != nil {
+					return err
+				}
 				i.log.Info("localstack: finished waiting")
 				return nil
 			}
@@ -514,6 +772,20 @@ func (i *Instance) isRunning(ctx context.Context) error {
 	return nil
 }
 
+func (i *Instance) checkReadiness(ctx context.Context) error {
+	if i.hasHealthEndpoint() {
+		return i.checkHealth(ctx)
+	}
+	return i.checkAvailable(ctx)
+}
+
+// hasHealthEndpoint returns true, when the version serves /_localstack/health.
+func (i *Instance) hasHealthEndpoint() bool {
+	return i.fixedPort && !i.legacyHealth
+}
+
+// checkAvailable creates and deletes a DynamoDB table,
+// as versions before 1.0.0 don't provide the health endpoint.
 func (i *Instance) checkAvailable(ctx context.Context) error {
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion("local"),
@@ -566,6 +838,33 @@ func (i *Instance) getContainerId() string {
 	return i.containerId
 }
 
+func (i *Instance) setServices(services []Service) {
+	i.containerIdMutex.Lock()
+	defer i.containerIdMutex.Unlock()
+	i.services = services
+}
+
+func (i *Instance) getServices() []Service {
+	i.containerIdMutex.RLock()
+	defer i.containerIdMutex.RUnlock()
+	return i.services
+}
+
+// serviceNames returns the names of the services localstack should start.
+// None are returned for starting all services.
+func (i *Instance) serviceNames(services []Service) []string {
+	var names []string
+	for _, service := range services {
+		if shouldBeAdded(service) && (i.hasHealthEndpoint() || service != DynamoDB) {
+			names = append(names, service.localstackName())
+		}
+	}
+	if len(names) > 0 && !i.hasHealthEndpoint() {
+		names = append([]string{"dynamodb"}, names...) // for checkAvailable
+	}
+	return names
+}
+
 func (i *Instance) resetPortMapping() {
 	i.savePortMappings(map[Service]string{})
 }
@@ -583,7 +882,7 @@ func (i *Instance) getPortMapping(service Service) string {
 }
 
 func shouldBeAdded(service Service) bool {
-	return service != DynamoDB && service != FixedPort && service != ES
+	return service != FixedPort && service != ES
 }
 
 func containsService(services []Service, service Service) bool {
@@ -634,3 +933,15 @@ type containerMissing struct {
 func (c containerMissing) Error() string {
 	return c.err.Error()
 }
+
+type dockerUnreachable struct {
+	err error
+}
+
+func (d dockerUnreachable) Error() string {
+	return d.err.Error()
+}
+
+func (d dockerUnreachable) Unwrap() error {
+	return d.err
+}
@@ -18,13 +18,17 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/docker/docker/api/types/build"
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/go-connections/nat"
 	"github.com/elgohr/go-localstack/internal/internalfakes"
 	"github.com/sirupsen/logrus"
@@ -50,33 +54,65 @@ func TestInstance_Start_Fails(t *testing.T) {
 			},
 			then: func(t *testing.T, err error, f *internalfakes.FakeDockerClient) {
 				require.EqualError(t, err, "localstack: can't stop an already running instance: can't stop")
-				require.Equal(t, 0, f.ImageBuildCallCount())
+				require.Equal(t, 0, f.ImageInspectCallCount())
 				require.Equal(t, 0, f.ContainerCreateCallCount())
 				require.Equal(t, 0, f.ContainerStartCallCount())
 				require.Equal(t, 0, f.ContainerInspectCallCount())
 			},
 		},
 		{
-			when: "can't build image",
+			when: "can't inspect image",
 			given: func(f *internalfakes.FakeDockerClient) *Instance {
-				f.ImageBuildReturns(build.ImageBuildResponse{}, errors.New("can't build"))
+				f.ImageInspectReturns(image.InspectResponse{}, errors.New("can't inspect"))
 				return &Instance{
 					cli: f,
 					log: logrus.StandardLogger(),
 				}
 			},
 			then: func(t *testing.T, err error, f *internalfakes.FakeDockerClient) {
-				require.EqualError(t, err, "localstack: could not build image: can't build")
-				require.Equal(t, 1, f.ImageBuildCallCount())
+				require.EqualError(t, err, "localstack: could not inspect image: can't inspect")
+				require.Equal(t, 0, f.ImagePullCallCount())
+				require.Equal(t, 0, f.ContainerCreateCallCount())
+			},
+		},
+		{
+			when: "can't pull image",
+			given: func(f *internalfakes.FakeDockerClient) *Instance {
+				f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+				f.ImagePullReturns(nil, errors.New("can't pull"))
+				return &Instance{
+					cli: f,
+					log: logrus.StandardLogger(),
+				}
+			},
+			then: func(t *testing.T, err error, f *internalfakes.FakeDockerClient) {
+				require.EqualError(t, err, "localstack: could not pull image: can't pull")
+				require.Equal(t, 1, f.ImagePullCallCount())
 				require.Equal(t, 0, f.ContainerCreateCallCount())
 				require.Equal(t, 0, f.ContainerStartCallCount())
 				require.Equal(t, 0, f.ContainerInspectCallCount())
 			},
 		},
 		{
-			when: "can't close after building image",
+			when: "pulling the image fails",
 			given: func(f *internalfakes.FakeDockerClient) *Instance {
-				f.ImageBuildReturns(build.ImageBuildResponse{Body: ErrCloser(strings.NewReader(""), errors.New("can't close"))}, nil)
+				f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+				f.ImagePullReturns(io.NopCloser(strings.NewReader(`{"status": "Pulling"}{"error": "manifest unknown"}`)), nil)
+				return &Instance{
+					cli: f,
+					log: logrus.StandardLogger(),
+				}
+			},
+			then: func(t *testing.T, err error, f *internalfakes.FakeDockerClient) {
+				require.EqualError(t, err, "localstack: could not pull image: manifest unknown")
+				require.Equal(t, 0, f.ContainerCreateCallCount())
+			},
+		},
+		{
+			when: "can't close after pulling image",
+			given: func(f *internalfakes.FakeDockerClient) *Instance {
+				f.ImageInspectReturnsOnCall(0, image.InspectResponse{}, cerrdefs.ErrNotFound)
+				f.ImagePullReturns(ErrCloser(strings.NewReader(""), errors.New("can't close")), nil)
 				f.ContainerCreateReturns(container.CreateResponse{}, errors.New("can't create"))
 				return &Instance{
 					cli: f,
@@ -90,21 +126,29 @@ func TestInstance_Start_Fails(t *testing.T) {
 		{
 			when: "can't create container",
 			given: func(f *internalfakes.FakeDockerClient) *Instance {
-				f.ImageBuildReturns(build.ImageBuildResponse{Body: io.NopCloser(strings.NewReader(""))}, nil)
+				f.ImageInspectReturns(image.InspectResponse{ID: "sha256:localstack"}, nil)
 				f.ContainerCreateReturns(container.CreateResponse{}, errors.New("can't create"))
 				return &Instance{
-					cli: f,
-					log: logrus.StandardLogger(),
+					cli:     f,
+					log:     logrus.StandardLogger(),
+					image:   defaultImage,
+					version: LatestVersion,
+					timeout: 5 * time.Minute,
 				}
 			},
 			then: func(t *testing.T, err error, f *internalfakes.FakeDockerClient) {
 				require.EqualError(t, err, "localstack: could not create container: can't create")
-				require.Equal(t, 1, f.ImageBuildCallCount())
+				require.Equal(t, 1, f.ImageInspectCallCount())
+				require.Equal(t, 0, f.ImagePullCallCount())
 				require.Equal(t, 1, f.ContainerCreateCallCount())
 				ctx, config, hostConfig, networkingConfig, platform, containerName := f.ContainerCreateArgsForCall(0)
 				require.NotNil(t, ctx)
 				require.Equal(t, &container.Config{
-					Image:        "go-localstack",
+					Image:      "sha256:localstack",
+					Entrypoint: []string{"timeout", "-s", "SIGKILL", "300", "docker-entrypoint.sh"},
+					Labels: mergeLabels((&Instance{}).ownerLabels(), map[string]string{
+						labelImage: "localstack/localstack:latest",
+					}),
 					Env:          []string{},
 					Tty:          true,
 					AttachStdout: true,
@@ -128,7 +172,6 @@ func TestInstance_Start_Fails(t *testing.T) {
 		{
 			when: "can't start container",
 			given: func(f *internalfakes.FakeDockerClient) *Instance {
-				f.ImageBuildReturns(build.ImageBuildResponse{Body: io.NopCloser(strings.NewReader(""))}, nil)
 				f.ContainerStartReturns(errors.New("can't start"))
 				return &Instance{
 					cli: f,
@@ -137,7 +180,7 @@ func TestInstance_Start_Fails(t *testing.T) {
 			},
 			then: func(t *testing.T, err error, f *internalfakes.FakeDockerClient) {
 				require.EqualError(t, err, "localstack: could not start container: can't start")
-				require.Equal(t, 1, f.ImageBuildCallCount())
+				require.Equal(t, 1, f.ImageInspectCallCount())
 				require.Equal(t, 1, f.ContainerCreateCallCount())
 				require.Equal(t, 1, f.ContainerStartCallCount())
 				require.Equal(t, 0, f.ContainerInspectCallCount())
@@ -146,7 +189,6 @@ func TestInstance_Start_Fails(t *testing.T) {
 		{
 			when: "container inspect doesn't contain ports",
 			given: func(f *internalfakes.FakeDockerClient) *Instance {
-				f.ImageBuildReturns(build.ImageBuildResponse{Body: io.NopCloser(strings.NewReader(""))}, nil)
 				f.ContainerInspectReturns(container.InspectResponse{NetworkSettings: &container.NetworkSettings{
 					// will remove when removed
 					NetworkSettingsBase: container.NetworkSettingsBase{ //nolint:staticcheck
@@ -161,7 +203,7 @@ func TestInstance_Start_Fails(t *testing.T) {
 			},
 			then: func(t *testing.T, err error, f *internalfakes.FakeDockerClient) {
 				require.EqualError(t, err, "localstack: could not get port from container")
-				require.Equal(t, 1, f.ImageBuildCallCount())
+				require.Equal(t, 1, f.ImageInspectCallCount())
 				require.Equal(t, 1, f.ContainerCreateCallCount())
 				require.Equal(t, 1, f.ContainerStartCallCount())
 				require.Equal(t, 11, f.ContainerInspectCallCount())
@@ -188,6 +230,22 @@ func TestInstance_StartWithContext_Fails_Stop_AfterTest(t *testing.T) {
 	require.EqualError(t, i.StartWithContext(ctx), "localstack: can't stop an already running instance: can't stop")
 }
 
+func TestInstance_StartCtx_KeepsRunning(t *testing.T) {
+	t.Parallel()
+	f := startableFake(t)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), fixedPort: true, portMapping: map[Service]string{}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	require.NoError(t, i.StartCtx(ctx))
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	require.Equal(t, "started", i.ContainerID())
+	require.Equal(t, 0, f.ContainerStopCallCount())
+	require.NoError(t, i.Stop())
+	require.Empty(t, i.ContainerID())
+}
+
 func TestInstance_Stop_Fails(t *testing.T) {
 	t.Parallel()
 	f := &internalfakes.FakeDockerClient{}
@@ -216,6 +274,29 @@ func TestInstance_waitToBeAvailable_Context_Expired(t *testing.T) {
 	require.Error(t, i.waitToBeAvailable(ctx))
 }
 
+// startableFake fakes a docker client, which starts a container answering like localstack.
+func startableFake(t *testing.T) *internalfakes.FakeDockerClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerCreateReturns(container.CreateResponse{ID: "started"}, nil)
+	f.ContainerInspectReturns(container.InspectResponse{NetworkSettings: &container.NetworkSettings{
+		// will remove when removed
+		NetworkSettingsBase: container.NetworkSettingsBase{ //nolint:staticcheck
+			Ports: nat.PortMap{nat.Port(FixedPort.Port): {{HostPort: u.Port()}}},
+		},
+	}}, nil)
+	f.ContainerLogsStub = func(context.Context, string, container.LogsOptions) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return f
+}
+
 func ErrCloser(r io.Reader, err error) io.ReadCloser {
 	return errCloser{Reader: r, Error: err}
 }
@@ -124,7 +124,7 @@ func TestLocalStack(t *testing.T) {
 		containers, err := cli.ContainerList(ctx, container.ListOptions{})
 		require.NoError(t, err)
 		for _, c := range containers {
-			if strings.Contains(c.Image, "go-localstack") {
+			if c.Labels["go-localstack.managed"] == "true" {
 				t.Fatalf("%s is still running but should be terminated", c.Image)
 			}
 		}
@@ -144,7 +144,7 @@ func TestLocalStack(t *testing.T) {
 			containers, err := cli.ContainerList(ctx, container.ListOptions{})
 			require.NoError(t, err)
 			for _, c := range containers {
-				if strings.Contains(c.Image, "go-localstack") {
+				if c.Labels["go-localstack.managed"] == "true" {
 					return false
 				}
 			}
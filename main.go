@@ -0,0 +1,76 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"testing"
+)
+
+var (
+	mainInstance      *Instance
+	mainInstanceMutex sync.RWMutex
+)
+
+// RunMain starts an instance, runs the tests and stops the instance before exiting.
+// It's meant to be called from TestMain, while the tests get the instance by MainInstance.
+// The auth token is read from LOCALSTACK_AUTH_TOKEN, unless it's configured by WithAuthToken.
+// The instance is also stopped when TestMain panics. Panicking tests exit the process
+// without further notice, in which case the container is terminated by its lease (see WithLease).
+func RunMain(m *testing.M, opts ...InstanceOption) {
+	os.Exit(runMain(m, opts...))
+}
+
+// MainInstance returns the instance started by RunMain.
+// It's nil when RunMain isn't running.
+func MainInstance() *Instance {
+	mainInstanceMutex.RLock()
+	defer mainInstanceMutex.RUnlock()
+	return mainInstance
+}
+
+type testRunner interface {
+	Run() int
+}
+
+func runMain(m testRunner, opts ...InstanceOption) int {
+	ctx := context.Background()
+	i, err := newInstanceCtx(ctx, append([]InstanceOption{WithAuthToken(os.Getenv("LOCALSTACK_AUTH_TOKEN"))}, opts...)...)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer func() {
+		setMainInstance(nil)
+		if err := i.stop(); err != nil {
+			i.log.Error(err)
+		}
+	}()
+	if err := i.start(ctx); err != nil {
+		i.log.Error(err)
+		return 1
+	}
+	setMainInstance(i)
+	return m.Run()
+}
+
+func setMainInstance(i *Instance) {
+	mainInstanceMutex.Lock()
+	defer mainInstanceMutex.Unlock()
+	mainInstance = i
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMain(t *testing.T) {
+	f := startableFake(t)
+	m := &fakeRunner{code: 3, run: func() {
+		require.NotNil(t, MainInstance())
+		require.NotEmpty(t, MainInstance().EndpointV2(S3))
+	}}
+
+	require.Equal(t, 3, runMain(m, func(i *Instance) { i.cli = f }))
+
+	require.True(t, m.called)
+	require.Nil(t, MainInstance())
+	require.Equal(t, 1, f.ContainerStopCallCount())
+}
+
+func TestRunMain_Stops_OnPanic(t *testing.T) {
+	f := startableFake(t)
+	m := &fakeRunner{run: func() {
+		panic("test panics")
+	}}
+
+	require.PanicsWithValue(t, "test panics", func() {
+		runMain(m, func(i *Instance) { i.cli = f })
+	})
+
+	require.Nil(t, MainInstance())
+	require.Equal(t, 1, f.ContainerStopCallCount())
+}
+
+func TestRunMain_Fails_Start(t *testing.T) {
+	t.Setenv("LOCALSTACK_AUTH_TOKEN", "from-env")
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturns(image.InspectResponse{}, errors.New("can't inspect"))
+	m := &fakeRunner{}
+
+	require.Equal(t, 1, runMain(m, func(i *Instance) { i.cli = f }))
+
+	require.False(t, m.called)
+	require.Nil(t, MainInstance())
+}
+
+func TestRunMain_Fails_InvalidVersion(t *testing.T) {
+	m := &fakeRunner{}
+
+	require.Equal(t, 1, runMain(m, WithVersion("bad.version.34")))
+
+	require.False(t, m.called)
+}
+
+func TestWithAuthToken(t *testing.T) {
+	t.Parallel()
+	i := &Instance{}
+	WithAuthToken("token")(i)
+	require.Equal(t, "token", i.authToken)
+}
+
+type fakeRunner struct {
+	called bool
+	code   int
+	run    func()
+}
+
+func (f *fakeRunner) Run() int {
+	f.called = true
+	if f.run != nil {
+		f.run()
+	}
+	return f.code
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/network"
+)
+
+// ensureNetwork creates the network of the instance, when it doesn't exist.
+func (i *Instance) ensureNetwork(ctx context.Context) error {
+	_, err := i.cli.NetworkInspect(ctx, i.network, network.InspectOptions{})
+	if err == nil || !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	i.log.Infof("creating network %s", i.network)
+	_, err = i.cli.NetworkCreate(ctx, i.network, network.CreateOptions{Driver: "bridge"})
+	if cerrdefs.IsConflict(err) {
+		return nil // created concurrently
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_startLocalstack_Network(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when   string
+		given  func(f *internalfakes.FakeDockerClient) *Instance
+		expect func(t *testing.T, f *internalfakes.FakeDockerClient)
+	}{
+		{
+			when: "joining an existing network",
+			given: func(f *internalfakes.FakeDockerClient) *Instance {
+				return &Instance{cli: f, log: logrus.StandardLogger(), network: "tests", networkAliases: []string{"localstack"}}
+			},
+			expect: func(t *testing.T, f *internalfakes.FakeDockerClient) {
+				require.Equal(t, 0, f.NetworkInspectCallCount())
+				require.Equal(t, 0, f.NetworkCreateCallCount())
+			},
+		},
+		{
+			when: "creating a missing network",
+			given: func(f *internalfakes.FakeDockerClient) *Instance {
+				f.NetworkInspectReturns(network.Inspect{}, cerrdefs.ErrNotFound)
+				return &Instance{cli: f, log: logrus.StandardLogger(), network: "tests", networkAliases: []string{"localstack"}, createNetwork: true}
+			},
+			expect: func(t *testing.T, f *internalfakes.FakeDockerClient) {
+				require.Equal(t, 1, f.NetworkCreateCallCount())
+				_, name, options := f.NetworkCreateArgsForCall(0)
+				require.Equal(t, "tests", name)
+				require.Equal(t, "bridge", options.Driver)
+			},
+		},
+		{
+			when: "creating an existing network",
+			given: func(f *internalfakes.FakeDockerClient) *Instance {
+				return &Instance{cli: f, log: logrus.StandardLogger(), network: "tests", networkAliases: []string{"localstack"}, createNetwork: true}
+			},
+			expect: func(t *testing.T, f *internalfakes.FakeDockerClient) {
+				require.Equal(t, 1, f.NetworkInspectCallCount())
+				require.Equal(t, 0, f.NetworkCreateCallCount())
+			},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			f.ContainerCreateReturns(container.CreateResponse{}, errors.New("can't create"))
+
+			require.EqualError(t, s.given(f).startLocalstack(context.Background()), "localstack: could not create container: can't create")
+
+			_, _, _, networkingConfig, _, _ := f.ContainerCreateArgsForCall(0)
+			require.Equal(t, &network.NetworkingConfig{
+				EndpointsConfig: map[string]*network.EndpointSettings{
+					"tests": {Aliases: []string{"localstack"}},
+				},
+			}, networkingConfig)
+			s.expect(t, f)
+		})
+	}
+}
+
+func TestInstance_startLocalstack_Network_Fails(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.NetworkInspectReturns(network.Inspect{}, cerrdefs.ErrNotFound)
+	f.NetworkCreateReturns(network.CreateResponse{}, errors.New("can't create network"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), network: "tests", createNetwork: true}
+
+	require.EqualError(t, i.startLocalstack(context.Background()), "localstack: could not create network: can't create network")
+	require.Equal(t, 0, f.ContainerCreateCallCount())
+}
+
+func TestInstance_InternalEndpoint(t *testing.T) {
+	t.Parallel()
+	for _, s := range [...]struct {
+		when     string
+		instance *Instance
+		expect   string
+	}{
+		{
+			when:     "not running",
+			instance: &Instance{network: "tests", fixedPort: true},
+			expect:   "",
+		},
+		{
+			when:     "not joining a network",
+			instance: &Instance{containerId: "0123456789abcdef", fixedPort: true},
+			expect:   "",
+		},
+		{
+			when:     "having an alias",
+			instance: &Instance{containerId: "0123456789abcdef", network: "tests", networkAliases: []string{"localstack", "aws"}, fixedPort: true},
+			expect:   "http://localstack:4566",
+		},
+		{
+			when:     "having no alias",
+			instance: &Instance{containerId: "0123456789abcdef", network: "tests", fixedPort: true},
+			expect:   "http://0123456789ab:4566",
+		},
+		{
+			when:     "having individual ports",
+			instance: &Instance{containerId: "0123456789abcdef", network: "tests", networkAliases: []string{"localstack"}},
+			expect:   "http://localstack:4572",
+		},
+	} {
+		require.Equal(t, s.expect, s.instance.InternalEndpoint(S3), s.when)
+	}
+}
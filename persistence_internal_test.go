@@ -0,0 +1,61 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_startLocalstack_Persistence(t *testing.T) {
+	t.Parallel()
+	hostDir := filepath.Join(t.TempDir(), "state")
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerCreateReturns(container.CreateResponse{}, errors.New("can't create"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), persistenceDir: hostDir}
+
+	require.EqualError(t, i.startLocalstack(context.Background()), "localstack: could not create container: can't create")
+
+	require.DirExists(t, hostDir)
+	_, config, hostConfig, _, _, _ := f.ContainerCreateArgsForCall(0)
+	require.Contains(t, config.Env, "PERSISTENCE=1")
+	require.Equal(t, []mount.Mount{{
+		Type:   mount.TypeBind,
+		Source: hostDir,
+		Target: "/var/lib/localstack",
+	}}, hostConfig.Mounts)
+	require.True(t, hostConfig.AutoRemove)
+}
+
+func TestInstance_Stop_Persistence_Gracefully(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	i := &Instance{cli: f, log: logrus.StandardLogger(), containerId: "something", persistenceDir: t.TempDir()}
+
+	require.NoError(t, i.Stop())
+
+	_, containerId, options := f.ContainerStopArgsForCall(0)
+	require.Equal(t, "something", containerId)
+	require.Equal(t, "SIGTERM", options.Signal)
+	require.Equal(t, gracefulStopTimeout, *options.Timeout)
+}
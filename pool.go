@@ -0,0 +1,219 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+)
+
+// labelPool identifies the containers of a Pool.
+const labelPool = "go-localstack.pool"
+
+// Pool keeps warm instances for tests running in parallel.
+// Each test acquires its own instance, which is reset when the test is done.
+// Resources of WithSeed are created again after the reset, while init scripts are not run again.
+type Pool struct {
+	id        string
+	authToken string
+	opts      []InstanceOption
+	minIdle   int
+	maxIdle   int
+
+	mu      sync.Mutex
+	idle    []*Instance
+	pending int
+	closed  bool
+	warming sync.WaitGroup
+}
+
+// PoolOption is an option that controls the behaviour of a Pool.
+type PoolOption func(p *Pool)
+
+// WithMinIdle configures the number of instances that are kept warm.
+// The default is 0, so that instances are started when they are acquired.
+func WithMinIdle(n int) PoolOption {
+	return func(p *Pool) {
+		p.minIdle = n
+	}
+}
+
+// WithMaxIdle configures the number of released instances that are kept for the next tests.
+// Further instances are stopped on release. The default is 2.
+func WithMaxIdle(n int) PoolOption {
+	return func(p *Pool) {
+		p.maxIdle = n
+	}
+}
+
+// WithInstanceOptions configures the options of the instances in the pool.
+func WithInstanceOptions(opts ...InstanceOption) PoolOption {
+	return func(p *Pool) {
+		p.opts = append(p.opts, opts...)
+	}
+}
+
+// NewPool creates a Pool of authenticated instances and starts the configured number of idle instances.
+func NewPool(ctx context.Context, authToken string, opts ...PoolOption) (*Pool, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	p := &Pool{
+		id:        hex.EncodeToString(id),
+		authToken: authToken,
+		maxIdle:   2,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.maxIdle < p.minIdle {
+		p.maxIdle = p.minIdle
+	}
+
+	errs := make([]error, p.minIdle)
+	var wg sync.WaitGroup
+	for n := range p.minIdle {
+		wg.Go(func() {
+			i, err := p.newInstance(ctx)
+			if err != nil {
+				errs[n] = err
+				return
+			}
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.idle = append(p.idle, i)
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, errors.Join(err, p.Close())
+	}
+	return p, nil
+}
+
+// Acquire hands out an instance for the given test, which is released when the test is cleaned up.
+func (p *Pool) Acquire(t testing.TB) *Instance {
+	t.Helper()
+	i, err := p.acquire(t.Context())
+	if err != nil {
+		t.Fatalf("localstack: could not acquire instance: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := p.release(context.Background(), i); err != nil {
+			t.Errorf("localstack: could not release instance: %v", err)
+		}
+	})
+	return i
+}
+
+// Close stops all idle instances.
+// Acquired instances are stopped when they are released.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.warming.Wait()
+
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	errs := make([]error, 0, len(idle))
+	for _, i := range idle {
+		errs = append(errs, i.stop())
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) acquire(ctx context.Context) (*Instance, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("localstack: pool is closed")
+	}
+	var i *Instance
+	if len(p.idle) > 0 {
+		i = p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+	}
+	for ; len(p.idle)+p.pending < p.minIdle; p.pending++ {
+		p.warming.Go(p.warm)
+	}
+	p.mu.Unlock()
+
+	if i != nil {
+		return i, nil
+	}
+	return p.newInstance(ctx)
+}
+
+func (p *Pool) release(ctx context.Context, i *Instance) error {
+	if err := i.Reset(ctx); err != nil {
+		return errors.Join(err, i.stop())
+	}
+	if i.seeds != nil {
+		if err := i.seed(ctx, i.seeds); err != nil {
+			return errors.Join(err, i.stop())
+		}
+	}
+	p.mu.Lock()
+	if p.closed || len(p.idle) >= p.maxIdle {
+		p.mu.Unlock()
+		return i.stop()
+	}
+	p.idle = append(p.idle, i)
+	p.mu.Unlock()
+	return nil
+}
+
+// warm starts an idle instance in the background.
+func (p *Pool) warm() {
+	i, err := p.newInstance(context.Background())
+	p.mu.Lock()
+	p.pending--
+	if err != nil {
+		p.mu.Unlock()
+		log.Println(err)
+		return
+	}
+	if p.closed || len(p.idle) >= p.maxIdle {
+		p.mu.Unlock()
+		if err := i.stop(); err != nil {
+			i.log.Error(err)
+		}
+		return
+	}
+	p.idle = append(p.idle, i)
+	p.mu.Unlock()
+}
+
+func (p *Pool) newInstance(ctx context.Context) (*Instance, error) {
+	i, err := newInstanceCtx(ctx, append([]InstanceOption{WithAuthToken(p.authToken)}, p.opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	i.labels = mergeLabels(i.labels, map[string]string{labelPool: p.id})
+	if err := i.start(ctx); err != nil {
+		return nil, errors.Join(err, i.stop())
+	}
+	return i, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+	f := startableFake(t)
+	p, err := NewPool(context.Background(), "token",
+		WithMinIdle(1),
+		WithMaxIdle(1),
+		WithInstanceOptions(func(i *Instance) { i.cli = f }),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, f.ContainerCreateCallCount())
+	_, config, _, _, _, _ := f.ContainerCreateArgsForCall(0)
+	require.Equal(t, p.id, config.Labels[labelPool])
+	require.Contains(t, config.Env, "LOCALSTACK_AUTH_TOKEN=token")
+
+	tb := &fakeTB{TB: t}
+	first := p.Acquire(tb)
+	second := p.Acquire(tb)
+	require.NotSame(t, first, second)
+	require.NotEmpty(t, first.EndpointV2(S3))
+
+	for _, cleanup := range tb.cleanups {
+		cleanup()
+	}
+	require.Empty(t, tb.failed)
+	require.NoError(t, p.Close())
+
+	require.Equal(t, f.ContainerCreateCallCount(), f.ContainerStopCallCount())
+}
+
+func TestPool_ReusesReleasedInstance(t *testing.T) {
+	t.Parallel()
+	f := startableFake(t)
+	p, err := NewPool(context.Background(), "token", WithInstanceOptions(func(i *Instance) { i.cli = f }))
+	require.NoError(t, err)
+	require.Equal(t, 0, f.ContainerCreateCallCount())
+
+	first, err := p.acquire(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, p.release(context.Background(), first))
+	second, err := p.acquire(context.Background())
+	require.NoError(t, err)
+
+	require.Same(t, first, second)
+	require.Equal(t, 1, f.ContainerCreateCallCount())
+	require.Equal(t, 0, f.ContainerStopCallCount())
+	require.NoError(t, p.release(context.Background(), second))
+	require.NoError(t, p.Close())
+	require.Equal(t, 1, f.ContainerStopCallCount())
+}
+
+func TestPool_ReseedsReleasedInstance(t *testing.T) {
+	t.Parallel()
+	aws := &fakeAWS{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/_localstack") {
+			_, _ = w.Write([]byte("{}"))
+			return
+		}
+		aws.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	seeds, err := parseSeedManifest(strings.NewReader("ssm: {parameters: [{name: /app/key, value: value}]}"))
+	require.NoError(t, err)
+	i := runningInstance(server)
+	i.seeds = seeds
+	p := &Pool{maxIdle: 1}
+
+	require.NoError(t, p.release(context.Background(), i))
+
+	require.Equal(t, []string{"AmazonSSM.PutParameter"}, aws.calls)
+	require.Equal(t, []*Instance{i}, p.idle)
+}
+
+func TestPool_Fails_Start(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturns(image.InspectResponse{}, errors.New("can't inspect"))
+
+	_, err := NewPool(context.Background(), "token",
+		WithMinIdle(2),
+		WithInstanceOptions(func(i *Instance) { i.cli = f }),
+	)
+
+	require.EqualError(t, err, "localstack: could not inspect image: can't inspect\nlocalstack: could not inspect image: can't inspect")
+}
+
+func TestPool_Fails_WhenClosed(t *testing.T) {
+	t.Parallel()
+	p, err := NewPool(context.Background(), "token")
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+
+	_, err = p.acquire(context.Background())
+	require.EqualError(t, err, "localstack: pool is closed")
+}
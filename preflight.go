@@ -0,0 +1,211 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Masterminds/semver/v3"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/versions"
+
+	"github.com/elgohr/go-localstack/internal"
+)
+
+// Semver constraint that tests if the version requires an auth token.
+var authTokenRequired = internal.MustParseConstraint("> " + LastVersionBeforeAuthToken)
+
+// CheckStatus is the outcome of a preflight check.
+type CheckStatus int
+
+const (
+	// CheckPassed means that the check found no problem.
+	CheckPassed CheckStatus = iota
+	// CheckFailed means that instances won't start until the problem is fixed.
+	CheckFailed
+	// CheckSkipped means that the check couldn't run, because an earlier check failed.
+	CheckSkipped
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckPassed:
+		return "pass"
+	case CheckFailed:
+		return "fail"
+	default:
+		return "skip"
+	}
+}
+
+// Check is the result of a preflight check.
+type Check struct {
+	Name   string
+	Status CheckStatus
+	// Message describes what the check found.
+	Message string
+	// Hint describes how to fix a failed check.
+	Hint string
+}
+
+// Report contains the results of Preflight.
+type Report struct {
+	Checks []Check
+}
+
+// Passed returns true, when no check failed.
+func (r Report) Passed() bool {
+	for _, c := range r.Checks {
+		if c.Status == CheckFailed {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) add(name string, status CheckStatus, message, hint string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Message: message, Hint: hint})
+}
+
+// Preflight checks whether instances with the given options can start on this machine:
+// the docker client and daemon, the API version, the auth token, the image and binding ports.
+// The client is created like by NewAuthenticatedInstance, so that WithClientFromEnv is respected.
+func Preflight(ctx context.Context, opts ...InstanceOption) Report {
+	i, err := newInstanceCtx(ctx, opts...)
+	if err != nil {
+		report := Report{}
+		if errors.As(err, &dockerUnreachable{}) {
+			report.add("docker client", CheckFailed, err.Error(), "check the installation of docker")
+		} else {
+			report.add("configuration", CheckFailed, err.Error(), "fix the options of the instance")
+		}
+		return report
+	}
+	return i.preflight(ctx)
+}
+
+func (i *Instance) preflight(ctx context.Context) Report {
+	report := Report{}
+	report.add("docker client", CheckPassed, "connecting to "+i.cli.DaemonHost(), "")
+	daemon := i.checkDaemon(ctx, &report)
+	if daemon {
+		i.checkAPIVersion(ctx, &report)
+	} else {
+		report.add("api version", CheckSkipped, "docker is not reachable", "")
+	}
+	i.checkAuthToken(&report)
+	if daemon {
+		i.checkImage(ctx, &report)
+	} else {
+		report.add("image", CheckSkipped, "docker is not reachable", "")
+	}
+	checkPortBinding(&report)
+	return report
+}
+
+func (i *Instance) checkDaemon(ctx context.Context, report *Report) bool {
+	host := i.cli.DaemonHost()
+	if _, err := i.cli.Ping(ctx); err != nil {
+		hint := "start docker or use WithClientFromEnv for connecting to DOCKER_HOST"
+		if env := os.Getenv("DOCKER_HOST"); env != "" && env != host {
+			hint = fmt.Sprintf("DOCKER_HOST is %s, but instances connect to %s unless they use WithClientFromEnv", env, host)
+		}
+		report.add("docker daemon", CheckFailed, fmt.Sprintf("%s is not reachable: %v", host, err), hint)
+		return false
+	}
+	report.add("docker daemon", CheckPassed, host+" is reachable", "")
+	return true
+}
+
+func (i *Instance) checkAPIVersion(ctx context.Context, report *Report) {
+	server, err := i.cli.ServerVersion(ctx)
+	if err != nil {
+		report.add("api version", CheckFailed, fmt.Sprintf("could not get the version of docker: %v", err), "check the logs of docker")
+		return
+	}
+	client := i.cli.ClientVersion()
+	if versions.LessThan(client, server.MinAPIVersion) || versions.GreaterThan(client, server.APIVersion) {
+		report.add("api version", CheckFailed,
+			fmt.Sprintf("the client uses API %s, but docker %s supports %s to %s", client, server.Version, server.MinAPIVersion, server.APIVersion),
+			"unset DOCKER_API_VERSION or update docker")
+		return
+	}
+	report.add("api version", CheckPassed, fmt.Sprintf("API %s of docker %s", client, server.Version), "")
+}
+
+func (i *Instance) checkAuthToken(report *Report) {
+	if i.authToken != "" {
+		report.add("auth token", CheckPassed, "configured", "")
+		return
+	}
+	if i.version != LatestVersion {
+		if version, err := semver.NewVersion(i.version); err == nil && !authTokenRequired.Check(version) {
+			report.add("auth token", CheckPassed, "not required by version "+i.version, "")
+			return
+		}
+	}
+	report.add("auth token", CheckFailed, "version "+i.version+" requires an auth token",
+		"set LOCALSTACK_AUTH_TOKEN and pass it by NewAuthenticatedInstance or WithAuthToken")
+}
+
+func (i *Instance) checkImage(ctx context.Context, report *Report) {
+	ref := i.imageRef()
+	_, err := i.cli.ImageInspect(ctx, ref)
+	if err == nil {
+		report.add("image", CheckPassed, ref+" is available locally", "")
+		return
+	}
+	if !cerrdefs.IsNotFound(err) {
+		report.add("image", CheckFailed, fmt.Sprintf("could not inspect %s: %v", ref, err), "check the logs of docker")
+		return
+	}
+	if i.imageArchive != "" {
+		if _, err := os.Stat(i.imageArchive); err == nil {
+			report.add("image", CheckPassed, fmt.Sprintf("%s can be loaded from %s", ref, i.imageArchive), "")
+			return
+		}
+	}
+	if i.pullPolicy == PullNever {
+		report.add("image", CheckFailed, ref+" is not available locally and pulling is disabled",
+			"pull the image or provide it by WithImageArchive")
+		return
+	}
+	var auth string
+	if auth, err = i.encodedRegistryAuth(); err == nil {
+		_, err = i.cli.DistributionInspect(ctx, ref, auth)
+	}
+	if err != nil {
+		report.add("image", CheckFailed, fmt.Sprintf("%s can't be pulled: %v", ref, err),
+			"check the network and the credentials of the registry (WithRegistryAuth), or provide the image by WithImageArchive")
+		return
+	}
+	report.add("image", CheckPassed, ref+" can be pulled", "")
+}
+
+// checkPortBinding ensures that ports can be bound on all interfaces, like docker does for the ports of localstack.
+func checkPortBinding(report *Report) {
+	l, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		report.add("port binding", CheckFailed, fmt.Sprintf("could not bind a port: %v", err),
+			"allow binding random ports on all interfaces, which docker publishes the ports of localstack on")
+		return
+	}
+	logClose(l)
+	report.add("port binding", CheckPassed, "random ports can be bound", "")
+}
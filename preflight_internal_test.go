@@ -0,0 +1,218 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// reachableDocker returns a client of a docker, which has the image and supports the API of the client.
+func reachableDocker() *internalfakes.FakeDockerClient {
+	f := &internalfakes.FakeDockerClient{}
+	f.DaemonHostReturns("unix:///var/run/docker.sock")
+	f.ClientVersionReturns("1.47")
+	f.ServerVersionReturns(types.Version{Version: "27.5.1", APIVersion: "1.47", MinAPIVersion: "1.24"}, nil)
+	return f
+}
+
+func TestInstance_Preflight(t *testing.T) {
+	t.Parallel()
+	i := &Instance{cli: reachableDocker(), log: logrus.StandardLogger(), image: defaultImage, version: LatestVersion, authToken: "token"}
+	report := i.preflight(context.Background())
+	require.True(t, report.Passed())
+	require.Equal(t, []Check{
+		{Name: "docker client", Status: CheckPassed, Message: "connecting to unix:///var/run/docker.sock"},
+		{Name: "docker daemon", Status: CheckPassed, Message: "unix:///var/run/docker.sock is reachable"},
+		{Name: "api version", Status: CheckPassed, Message: "API 1.47 of docker 27.5.1"},
+		{Name: "auth token", Status: CheckPassed, Message: "configured"},
+		{Name: "image", Status: CheckPassed, Message: "localstack/localstack:latest is available locally"},
+		{Name: "port binding", Status: CheckPassed, Message: "random ports can be bound"},
+	}, report.Checks)
+}
+
+func TestInstance_Preflight_DockerUnreachable(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://remote:2376")
+	f := reachableDocker()
+	f.PingReturns(types.Ping{}, errors.New("connection refused"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: LatestVersion, authToken: "token"}
+
+	report := i.preflight(context.Background())
+	require.False(t, report.Passed())
+	require.Equal(t, Check{
+		Name:    "docker daemon",
+		Status:  CheckFailed,
+		Message: "unix:///var/run/docker.sock is not reachable: connection refused",
+		Hint:    "DOCKER_HOST is tcp://remote:2376, but instances connect to unix:///var/run/docker.sock unless they use WithClientFromEnv",
+	}, report.Checks[1])
+	require.Equal(t, CheckSkipped, report.Checks[2].Status)
+	require.Equal(t, CheckPassed, report.Checks[3].Status)
+	require.Equal(t, CheckSkipped, report.Checks[4].Status)
+	require.Equal(t, 0, f.ImageInspectCallCount())
+}
+
+func TestInstance_Preflight_Fails(t *testing.T) {
+	t.Parallel()
+	archive := filepath.Join(t.TempDir(), "localstack.tar")
+	require.NoError(t, os.WriteFile(archive, []byte("image"), 0o600))
+
+	for _, scenario := range [...]struct {
+		when   string
+		given  func(i *Instance, f *internalfakes.FakeDockerClient)
+		expect Check
+	}{
+		{
+			when: "the client uses a newer API than docker",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				f.ClientVersionReturns("1.48")
+			},
+			expect: Check{
+				Name:    "api version",
+				Status:  CheckFailed,
+				Message: "the client uses API 1.48, but docker 27.5.1 supports 1.24 to 1.47",
+				Hint:    "unset DOCKER_API_VERSION or update docker",
+			},
+		},
+		{
+			when: "the version of docker is unknown",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				f.ServerVersionReturns(types.Version{}, errors.New("can't get version"))
+			},
+			expect: Check{
+				Name:    "api version",
+				Status:  CheckFailed,
+				Message: "could not get the version of docker: can't get version",
+				Hint:    "check the logs of docker",
+			},
+		},
+		{
+			when: "the auth token is missing",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				i.authToken = ""
+			},
+			expect: Check{
+				Name:    "auth token",
+				Status:  CheckFailed,
+				Message: "version latest requires an auth token",
+				Hint:    "set LOCALSTACK_AUTH_TOKEN and pass it by NewAuthenticatedInstance or WithAuthToken",
+			},
+		},
+		{
+			when: "the auth token isn't required",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				i.authToken = ""
+				i.version = LastVersionBeforeAuthToken
+			},
+			expect: Check{Name: "auth token", Status: CheckPassed, Message: "not required by version 4.14.0"},
+		},
+		{
+			when: "the image can be pulled",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+			},
+			expect: Check{Name: "image", Status: CheckPassed, Message: "localstack/localstack:latest can be pulled"},
+		},
+		{
+			when: "the image can't be pulled",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+				f.DistributionInspectReturns(registry.DistributionInspect{}, errors.New("unauthorized"))
+			},
+			expect: Check{
+				Name:    "image",
+				Status:  CheckFailed,
+				Message: "localstack/localstack:latest can't be pulled: unauthorized",
+				Hint:    "check the network and the credentials of the registry (WithRegistryAuth), or provide the image by WithImageArchive",
+			},
+		},
+		{
+			when: "pulling is disabled",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+				i.pullPolicy = PullNever
+			},
+			expect: Check{
+				Name:    "image",
+				Status:  CheckFailed,
+				Message: "localstack/localstack:latest is not available locally and pulling is disabled",
+				Hint:    "pull the image or provide it by WithImageArchive",
+			},
+		},
+		{
+			when: "the image can be loaded from an archive",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+				i.pullPolicy = PullNever
+				i.imageArchive = archive
+			},
+			expect: Check{Name: "image", Status: CheckPassed, Message: "localstack/localstack:latest can be loaded from " + archive},
+		},
+		{
+			when: "the image can't be inspected",
+			given: func(i *Instance, f *internalfakes.FakeDockerClient) {
+				f.ImageInspectReturns(image.InspectResponse{}, errors.New("can't inspect"))
+			},
+			expect: Check{
+				Name:    "image",
+				Status:  CheckFailed,
+				Message: "could not inspect localstack/localstack:latest: can't inspect",
+				Hint:    "check the logs of docker",
+			},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := reachableDocker()
+			i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: LatestVersion, authToken: "token"}
+			s.given(i, f)
+
+			report := i.preflight(context.Background())
+			require.Equal(t, s.expect.Status == CheckPassed, report.Passed())
+			require.Contains(t, report.Checks, s.expect)
+		})
+	}
+}
+
+func TestPreflight_InvalidConfiguration(t *testing.T) {
+	t.Parallel()
+	report := Preflight(context.Background(), WithLease(time.Second))
+	require.False(t, report.Passed())
+	require.Equal(t, []Check{{
+		Name:    "configuration",
+		Status:  CheckFailed,
+		Message: "localstack: lease must be at least 3s",
+		Hint:    "fix the options of the instance",
+	}}, report.Checks)
+}
+
+func TestCheckStatus_String(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "pass", CheckPassed.String())
+	require.Equal(t, "fail", CheckFailed.String())
+	require.Equal(t, "skip", CheckSkipped.String())
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReadinessProbe checks a condition, which must hold before the instance is available,
+// like a bucket created by an init script or an active Lambda.
+// Check is retried until it returns nil.
+type ReadinessProbe interface {
+	Check(ctx context.Context, i *Instance) error
+}
+
+// ReadinessProbeFunc is a function used as ReadinessProbe.
+type ReadinessProbeFunc func(ctx context.Context, i *Instance) error
+
+// Check calls the function.
+func (f ReadinessProbeFunc) Check(ctx context.Context, i *Instance) error {
+	return f(ctx, i)
+}
+
+// defaultReadinessProbeTimeout limits the time a probe may take to pass, unless configured otherwise.
+const defaultReadinessProbeTimeout = time.Minute
+
+// NamedProbe names a probe in errors and limits the time it may take to pass.
+// A timeout of 0 uses the timeout of WithReadinessProbeTimeout.
+func NamedProbe(name string, timeout time.Duration, probe ReadinessProbe) ReadinessProbe {
+	return namedProbe{ReadinessProbe: probe, name: name, timeout: timeout}
+}
+
+type namedProbe struct {
+	ReadinessProbe
+	name    string
+	timeout time.Duration
+}
+
+// WithReadinessProbes configures probes, which must pass after localstack itself is available.
+// They are checked concurrently whenever the instance is waited for (on Start and Reset).
+func WithReadinessProbes(probes ...ReadinessProbe) InstanceOption {
+	return func(i *Instance) {
+		i.readinessProbes = append(i.readinessProbes, probes...)
+	}
+}
+
+// WithReadinessProbeTimeout limits the time each probe may take to pass,
+// unless it has its own timeout (see NamedProbe). The default is one minute.
+func WithReadinessProbeTimeout(timeout time.Duration) InstanceOption {
+	return func(i *Instance) {
+		i.readinessProbeTimeout = timeout
+	}
+}
+
+// checkReadinessProbes waits for all probes to pass and returns the ones, which never did.
+func (i *Instance) checkReadinessProbes(ctx context.Context) error {
+	errs := make([]error, len(i.readinessProbes))
+	var wg sync.WaitGroup
+	for n, probe := range i.readinessProbes {
+		named, ok := probe.(namedProbe)
+		if !ok {
+			named = namedProbe{ReadinessProbe: probe, name: fmt.Sprintf("#%d", n+1)}
+		}
+		if named.timeout <= 0 {
+			named.timeout = i.readinessProbeTimeout
+		}
+		if named.timeout <= 0 {
+			named.timeout = defaultReadinessProbeTimeout
+		}
+		wg.Go(func() {
+			errs[n] = i.waitForProbe(ctx, named)
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (i *Instance) waitForProbe(ctx context.Context, probe namedProbe) error {
+	ctx, cancel := context.WithTimeout(ctx, probe.timeout)
+	defer cancel()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		err := probe.Check(ctx, i)
+		if err == nil {
+			return nil
+		}
+		i.log.Debugf("readiness probe %s: %v", probe.name, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("localstack: readiness probe %s never passed: %w", probe.name, err)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_waitToBeAvailable_ReadinessProbes(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	var checks atomic.Int32
+	i := runningInstance(server)
+	WithReadinessProbes(ReadinessProbeFunc(func(_ context.Context, probed *Instance) error {
+		require.Same(t, i, probed)
+		if checks.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}))(i)
+
+	require.NoError(t, i.waitToBeAvailable(context.Background()))
+	require.Equal(t, int32(3), checks.Load())
+}
+
+func TestInstance_waitToBeAvailable_ReadinessProbes_Fail(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	i := runningInstance(server)
+	WithReadinessProbes(
+		NamedProbe("bucket", time.Millisecond, ReadinessProbeFunc(func(context.Context, *Instance) error {
+			return errors.New("bucket is missing")
+		})),
+		ReadinessProbeFunc(func(context.Context, *Instance) error {
+			return nil
+		}),
+		NamedProbe("lambda", 10*time.Millisecond, ReadinessProbeFunc(func(context.Context, *Instance) error {
+			return errors.New("lambda is pending")
+		})),
+	)(i)
+
+	require.EqualError(t, i.waitToBeAvailable(context.Background()),
+		"localstack: readiness probe bucket never passed: bucket is missing\n"+
+			"localstack: readiness probe lambda never passed: lambda is pending")
+}
+
+func TestInstance_checkReadinessProbes_Unnamed(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	i := &Instance{log: logrus.StandardLogger()}
+	WithReadinessProbes(ReadinessProbeFunc(func(context.Context, *Instance) error {
+		return errors.New("never")
+	}))(i)
+
+	require.EqualError(t, i.checkReadinessProbes(ctx), "localstack: readiness probe #1 never passed: never")
+}
+
+func TestInstance_Start_ReadinessProbes_Timeout(t *testing.T) {
+	t.Parallel()
+	f := startableFake(t)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), fixedPort: true, portMapping: map[Service]string{}}
+	WithReadinessProbeTimeout(10 * time.Millisecond)(i)
+	WithReadinessProbes(
+		ReadinessProbeFunc(func(context.Context, *Instance) error {
+			return errors.New("bucket is missing")
+		}),
+		ReadinessProbeFunc(func(context.Context, *Instance) error {
+			return errors.New("lambda is pending")
+		}),
+	)(i)
+	t.Cleanup(func() { require.NoError(t, i.Stop()) })
+
+	require.EqualError(t, i.Start(),
+		"localstack: readiness probe #1 never passed: bucket is missing\n"+
+			"localstack: readiness probe #2 never passed: lambda is pending")
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// PullPolicy decides when the image is pulled.
+type PullPolicy int
+
+const (
+	// PullIfMissing pulls the image, when it's not available locally (default).
+	PullIfMissing PullPolicy = iota
+	// PullAlways pulls the image on every start, e.g. for keeping latest up to date.
+	PullAlways
+	// PullNever fails, when the image is not available locally.
+	PullNever
+)
+
+// WithPullPolicy configures when the image is pulled.
+func WithPullPolicy(policy PullPolicy) InstanceOption {
+	return func(i *Instance) {
+		i.pullPolicy = policy
+	}
+}
+
+// PullEvent reports the progress of pulling the image.
+type PullEvent struct {
+	// ID is the ID of the layer, when the event concerns a single layer.
+	ID string
+	// Status is the state of the pull or layer, e.g. Downloading or Pull complete.
+	Status string
+	// Current and Total are the bytes processed and to be processed, when known.
+	Current int64
+	Total   int64
+}
+
+// WithPullProgress configures a callback receiving the progress of pulling the image.
+// Without a callback, completed layers and the downloaded bytes are logged at info level.
+func WithPullProgress(progress func(PullEvent)) InstanceOption {
+	return func(i *Instance) {
+		i.pullProgress = progress
+	}
+}
+
+func (i *Instance) pullImage(ctx context.Context) error {
+	auth, err := i.encodedRegistryAuth()
+	if err != nil {
+		return err
+	}
+	i.log.Infof("pulling %s", i.imageRef())
+	reader, err := i.cli.ImagePull(ctx, i.imageRef(), image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		return err
+	}
+	defer logClose(reader)
+
+	progress := i.pullProgress
+	if progress == nil {
+		progress = newPullLog(i.log, i.imageRef(), time.Now).progress
+	}
+	return readPullStream(reader, progress)
+}
+
+// pullLogInterval limits how often the downloaded bytes are logged.
+const pullLogInterval = 5 * time.Second
+
+// pullLog logs the progress of a pull at info level, as the first pull takes minutes.
+// It logs every completed layer and the downloaded bytes at most every pullLogInterval.
+type pullLog struct {
+	log    *logrus.Logger
+	ref    string
+	now    func() time.Time
+	layers map[string]PullEvent
+	logged time.Time
+}
+
+func newPullLog(log *logrus.Logger, ref string, now func() time.Time) *pullLog {
+	return &pullLog{log: log, ref: ref, now: now, layers: map[string]PullEvent{}, logged: now()}
+}
+
+func (p *pullLog) progress(event PullEvent) {
+	p.log.Debugf("pulling %s: %s %s", p.ref, event.ID, event.Status)
+	switch event.Status {
+	case "Pulling fs layer", "Waiting":
+		p.layers[event.ID] = event
+	case "Downloading":
+		p.layers[event.ID] = event
+		if p.now().Sub(p.logged) >= pullLogInterval {
+			p.logged = p.now()
+			current, total := p.downloaded()
+			p.log.Infof("pulling %s: downloaded %.1f of %.1f MB", p.ref, megabytes(current), megabytes(total))
+		}
+	case "Verifying Checksum", "Download complete", "Extracting", "Pull complete", "Already exists":
+		// the layer is downloaded, the bytes of extracting aren't downloaded ones
+		layer := p.layers[event.ID]
+		layer.ID, layer.Status, layer.Current = event.ID, event.Status, layer.Total
+		p.layers[event.ID] = layer
+		if event.Status == "Pull complete" || event.Status == "Already exists" {
+			p.log.Infof("pulling %s: %d of %d layers complete", p.ref, p.complete(), len(p.layers))
+		}
+	}
+}
+
+// downloaded sums the bytes of the layers, which are downloading or downloaded.
+func (p *pullLog) downloaded() (current, total int64) {
+	for _, layer := range p.layers {
+		if layer.Total > 0 {
+			total += layer.Total
+			current += min(layer.Current, layer.Total)
+		}
+	}
+	return current, total
+}
+
+func (p *pullLog) complete() int {
+	complete := 0
+	for _, layer := range p.layers {
+		if layer.Status == "Pull complete" || layer.Status == "Already exists" {
+			complete++
+		}
+	}
+	return complete
+}
+
+func megabytes(bytes int64) float64 {
+	return float64(bytes) / 1e6
+}
+
+// encodedRegistryAuth returns the credentials of WithRegistryAuth for the API of docker.
+func (i *Instance) encodedRegistryAuth() (string, error) {
+	if i.registryAuth == nil {
+		return "", nil
+	}
+	return registry.EncodeAuthConfig(*i.registryAuth)
+}
+
+// pullMessage is a message of the progress stream of Docker.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// readPullStream reports the progress of a pull until it's done and returns the error it reported.
+func readPullStream(reader io.Reader, progress func(PullEvent)) error {
+	decoder := json.NewDecoder(reader)
+	for {
+		var message pullMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		progress(PullEvent{
+			ID:      message.ID,
+			Status:  message.Status,
+			Current: message.ProgressDetail.Current,
+			Total:   message.ProgressDetail.Total,
+		})
+	}
+}
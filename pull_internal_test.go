@@ -0,0 +1,165 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/image"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+const pullStream = `{"status": "Pulling from localstack/localstack", "id": "4.0.0"}
+{"status": "Downloading", "progressDetail": {"current": 10, "total": 20}, "progress": "[=====>     ]", "id": "layer"}
+{"status": "Pull complete", "progressDetail": {}, "id": "layer"}
+`
+
+func TestInstance_ensureImage_PullPolicy(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when   string
+		policy PullPolicy
+		given  func(f *internalfakes.FakeDockerClient)
+		expect string
+		pulls  int
+	}{
+		{
+			when:   "the image is present and pulled if missing",
+			policy: PullIfMissing,
+			pulls:  0,
+		},
+		{
+			when:   "the image is missing and pulled if missing",
+			policy: PullIfMissing,
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageInspectReturnsOnCall(0, image.InspectResponse{}, cerrdefs.ErrNotFound)
+			},
+			pulls: 1,
+		},
+		{
+			when:   "the image is present and always pulled",
+			policy: PullAlways,
+			pulls:  1,
+		},
+		{
+			when:   "the image is missing and never pulled",
+			policy: PullNever,
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImageInspectReturns(image.InspectResponse{}, cerrdefs.ErrNotFound)
+			},
+			expect: "localstack/localstack:4.0.0 is not available locally and pulling is disabled",
+		},
+		{
+			when:   "the pull is denied",
+			policy: PullAlways,
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ImagePullReturns(io.NopCloser(strings.NewReader(`{"errorDetail": {"message": "unauthorized"}, "error": "unauthorized"}`)), nil)
+			},
+			expect: "could not pull image: unauthorized",
+			pulls:  1,
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			f.ImageInspectReturns(image.InspectResponse{ID: "sha256:localstack"}, nil)
+			f.ImagePullReturns(io.NopCloser(strings.NewReader(pullStream)), nil)
+			if s.given != nil {
+				s.given(f)
+			}
+			i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+			WithPullPolicy(s.policy)(i)
+
+			imageId, err := i.ensureImage(context.Background())
+			if s.expect == "" {
+				require.NoError(t, err)
+				require.Equal(t, "sha256:localstack", imageId)
+			} else {
+				require.EqualError(t, err, s.expect)
+			}
+			require.Equal(t, s.pulls, f.ImagePullCallCount())
+		})
+	}
+}
+
+func TestInstance_pullImage_Progress(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ImagePullReturns(io.NopCloser(strings.NewReader(pullStream)), nil)
+	var events []PullEvent
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+	WithPullProgress(func(event PullEvent) {
+		events = append(events, event)
+	})(i)
+
+	require.NoError(t, i.pullImage(context.Background()))
+	require.Equal(t, []PullEvent{
+		{ID: "4.0.0", Status: "Pulling from localstack/localstack"},
+		{ID: "layer", Status: "Downloading", Current: 10, Total: 20},
+		{ID: "layer", Status: "Pull complete"},
+	}, events)
+}
+
+func TestPullLog(t *testing.T) {
+	t.Parallel()
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	now := time.Unix(0, 0)
+	p := newPullLog(logger, "localstack/localstack:4.0.0", func() time.Time { return now })
+
+	for _, event := range []PullEvent{
+		{ID: "4.0.0", Status: "Pulling from localstack/localstack"},
+		{ID: "cached", Status: "Already exists"},
+		{ID: "small", Status: "Pulling fs layer"},
+		{ID: "large", Status: "Pulling fs layer"},
+		{ID: "small", Status: "Downloading", Current: 1e6, Total: 2e6},
+		{ID: "small", Status: "Download complete"},
+		{ID: "small", Status: "Extracting", Current: 5e6, Total: 5e6},
+		{ID: "small", Status: "Pull complete"},
+		{ID: "large", Status: "Downloading", Current: 10e6, Total: 100e6},
+	} {
+		p.progress(event)
+	}
+	now = now.Add(pullLogInterval)
+	p.progress(PullEvent{ID: "large", Status: "Downloading", Current: 20e6, Total: 100e6})
+	p.progress(PullEvent{ID: "large", Status: "Downloading", Current: 30e6, Total: 100e6})
+	p.progress(PullEvent{ID: "large", Status: "Pull complete"})
+
+	require.Equal(t, `level=info msg="pulling localstack/localstack:4.0.0: 1 of 1 layers complete"
+level=info msg="pulling localstack/localstack:4.0.0: 2 of 3 layers complete"
+level=info msg="pulling localstack/localstack:4.0.0: downloaded 22.0 of 102.0 MB"
+level=info msg="pulling localstack/localstack:4.0.0: 3 of 3 layers complete"
+`, buf.String())
+}
+
+func TestInstance_pullImage_Fails_InvalidStream(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ImagePullReturns(io.NopCloser(strings.NewReader("{")), nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), image: defaultImage, version: "4.0.0"}
+
+	require.EqualError(t, i.pullImage(context.Background()), "unexpected EOF")
+}
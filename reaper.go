@@ -0,0 +1,162 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/elgohr/go-localstack/internal"
+)
+
+// Labels of every container created by go-localstack.
+const (
+	labelManaged = "go-localstack.managed"
+	labelSession = "go-localstack.session"
+	labelPid     = "go-localstack.pid"
+	labelHost    = "go-localstack.host"
+)
+
+// sessionId identifies the containers created by this process.
+var sessionId = newSessionId()
+
+func newSessionId() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // never returns an error
+	return hex.EncodeToString(b)
+}
+
+// SessionID returns the ID of the current process, as used in Filter.
+func SessionID() string {
+	return sessionId
+}
+
+// ownerLabels identify the process owning a container.
+// Reused and shared containers are owned by no process, as they outlive it.
+func (i *Instance) ownerLabels() map[string]string {
+	labels := map[string]string{labelManaged: "true"}
+	if i.reuse || i.shared {
+		return labels
+	}
+	labels[labelSession] = sessionId
+	labels[labelPid] = strconv.Itoa(os.Getpid())
+	if host, err := os.Hostname(); err == nil {
+		labels[labelHost] = host
+	}
+	return labels
+}
+
+// Filter selects containers created by go-localstack.
+type Filter struct {
+	// ID selects a container by its ID or a prefix of it.
+	ID string
+	// Session selects the containers of a session (see SessionID).
+	Session string
+	// Labels selects containers with all the given labels (see WithLabels).
+	Labels map[string]string
+	// Orphaned selects containers, whose owning process on this host doesn't exist anymore.
+	// Reused and shared containers are never orphaned.
+	Orphaned bool
+}
+
+// WithReaper configures the instance to remove orphaned containers (see Filter) before starting.
+// They are left behind by test processes, which crashed or were killed before stopping their instances.
+func WithReaper() InstanceOption {
+	return func(i *Instance) {
+		i.reaper = true
+	}
+}
+
+// Reap removes the containers created by go-localstack that match the filter and returns their IDs.
+func Reap(ctx context.Context, filter Filter) ([]string, error) {
+	cli, err := newDockerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer logClose(cli)
+	return reap(ctx, cli, filter)
+}
+
+func reap(ctx context.Context, cli internal.DockerClient, filter Filter) ([]string, error) {
+	containers, err := listContainers(ctx, cli, filter)
+	if err != nil {
+		return nil, fmt.Errorf("localstack: could not list containers: %w", err)
+	}
+	removed := make([]string, 0, len(containers))
+	for _, c := range containers {
+		if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true}); err != nil {
+			return removed, fmt.Errorf("localstack: could not remove container %s: %w", c.ID, err)
+		}
+		removed = append(removed, c.ID)
+	}
+	return removed, nil
+}
+
+// listContainers returns the containers created by go-localstack that match the filter.
+func listContainers(ctx context.Context, cli internal.DockerClient, filter Filter) ([]container.Summary, error) {
+	labels := maps.Clone(filter.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[labelManaged] = "true"
+	if filter.Session != "" {
+		labels[labelSession] = filter.Session
+	}
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", k+"="+v)
+	}
+	if filter.ID != "" {
+		args.Add("id", filter.ID)
+	}
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	if !filter.Orphaned {
+		return containers, nil
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	orphaned := containers[:0]
+	for _, c := range containers {
+		if isOrphaned(c.Labels, host) {
+			orphaned = append(orphaned, c)
+		}
+	}
+	return orphaned, nil
+}
+
+// isOrphaned returns true, when the owning process of a container on the host doesn't exist anymore.
+func isOrphaned(labels map[string]string, host string) bool {
+	if labels[labelHost] != host {
+		return false
+	}
+	pid, err := strconv.Atoi(labels[labelPid])
+	if err != nil || pid <= 0 {
+		return false
+	}
+	return !processExists(pid)
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_ownerLabels(t *testing.T) {
+	t.Parallel()
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		labelManaged: "true",
+		labelSession: SessionID(),
+		labelPid:     strconv.Itoa(os.Getpid()),
+		labelHost:    host,
+	}, (&Instance{}).ownerLabels())
+	require.Equal(t, map[string]string{labelManaged: "true"}, (&Instance{reuse: true}).ownerLabels())
+	require.Equal(t, map[string]string{labelManaged: "true"}, (&Instance{shared: true}).ownerLabels())
+}
+
+// deadPid is above the maximum pid of Linux and Windows.
+const deadPid = "1073741823"
+
+func TestReap(t *testing.T) {
+	t.Parallel()
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	containers := []container.Summary{
+		{ID: "running", Labels: map[string]string{labelHost: host, labelPid: strconv.Itoa(os.Getpid())}},
+		{ID: "orphaned", Labels: map[string]string{labelHost: host, labelPid: deadPid}},
+		{ID: "other-host", Labels: map[string]string{labelHost: "other", labelPid: deadPid}},
+		{ID: "reused", Labels: map[string]string{}},
+	}
+	for _, scenario := range [...]struct {
+		when          string
+		filter        Filter
+		expectFilters filters.Args
+		expect        []string
+	}{
+		{
+			when:          "reaping all containers",
+			expectFilters: filters.NewArgs(filters.Arg("label", labelManaged+"=true")),
+			expect:        []string{"running", "orphaned", "other-host", "reused"},
+		},
+		{
+			when:   "reaping containers of a session with labels",
+			filter: Filter{Session: "session", Labels: map[string]string{"team": "a"}},
+			expectFilters: filters.NewArgs(
+				filters.Arg("label", labelManaged+"=true"),
+				filters.Arg("label", labelSession+"=session"),
+				filters.Arg("label", "team=a"),
+			),
+			expect: []string{"running", "orphaned", "other-host", "reused"},
+		},
+		{
+			when:   "reaping a container by ID",
+			filter: Filter{ID: "abc"},
+			expectFilters: filters.NewArgs(
+				filters.Arg("label", labelManaged+"=true"),
+				filters.Arg("id", "abc"),
+			),
+			expect: []string{"running", "orphaned", "other-host", "reused"},
+		},
+		{
+			when:          "reaping orphaned containers",
+			filter:        Filter{Orphaned: true},
+			expectFilters: filters.NewArgs(filters.Arg("label", labelManaged+"=true")),
+			expect:        []string{"orphaned"},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			f.ContainerListReturns(append([]container.Summary{}, containers...), nil)
+
+			removed, err := reap(context.Background(), f, s.filter)
+			require.NoError(t, err)
+			require.Equal(t, s.expect, removed)
+
+			_, options := f.ContainerListArgsForCall(0)
+			require.True(t, options.All)
+			require.Equal(t, s.expectFilters, options.Filters)
+			require.Equal(t, len(s.expect), f.ContainerRemoveCallCount())
+			for n, id := range s.expect {
+				_, removedId, removeOptions := f.ContainerRemoveArgsForCall(n)
+				require.Equal(t, id, removedId)
+				require.True(t, removeOptions.Force)
+			}
+		})
+	}
+}
+
+func TestReap_Fails(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when    string
+		given   func(f *internalfakes.FakeDockerClient)
+		expect  string
+		removed []string
+	}{
+		{
+			when: "containers can't be listed",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ContainerListReturns(nil, errors.New("can't list"))
+			},
+			expect: "localstack: could not list containers: can't list",
+		},
+		{
+			when: "a container can't be removed",
+			given: func(f *internalfakes.FakeDockerClient) {
+				f.ContainerListReturns([]container.Summary{{ID: "first"}, {ID: "second"}}, nil)
+				f.ContainerRemoveReturnsOnCall(1, errors.New("can't remove"))
+			},
+			expect:  "localstack: could not remove container second: can't remove",
+			removed: []string{"first"},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			f := &internalfakes.FakeDockerClient{}
+			s.given(f)
+			removed, err := reap(context.Background(), f, Filter{})
+			require.EqualError(t, err, s.expect)
+			if s.removed != nil {
+				require.Equal(t, s.removed, removed)
+			}
+		})
+	}
+}
+
+func TestInstance_Start_Reaper(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerListReturns(nil, errors.New("can't list"))
+	i := &Instance{cli: f, log: logrus.StandardLogger()}
+	WithReaper()(i)
+
+	require.EqualError(t, i.Start(), "localstack: could not list containers: can't list")
+	require.Equal(t, 0, f.ContainerCreateCallCount())
+}
+
+func Test_processExists(t *testing.T) {
+	t.Parallel()
+	require.True(t, processExists(os.Getpid()))
+	require.False(t, processExists(1073741823))
+}
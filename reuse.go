@@ -0,0 +1,93 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// labelConfigHash identifies containers that can be adopted by instances with the same configuration.
+const labelConfigHash = "go-localstack.config-hash"
+
+// configHash derives a stable identifier for the container configuration of the instance.
+func (i *Instance) configHash(spec containerSpec) (string, error) {
+	content, err := json.Marshal(struct {
+		Image   string
+		Version string
+		Timeout time.Duration
+		Spec    containerSpec
+	}{
+		Image:   i.image,
+		Version: i.version,
+		Timeout: i.timeout,
+		Spec:    spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+// reuseContainer adopts a running container with the given configuration hash
+// or creates a new one, which is labelled for being adopted later on.
+func (i *Instance) reuseContainer(ctx context.Context, services []Service, hash string, spec containerSpec) error {
+	adopted, err := i.adoptContainer(ctx, services, hash)
+	if err != nil || adopted {
+		return err
+	}
+	spec.Config.Labels = mergeLabels(i.labels, map[string]string{labelConfigHash: hash})
+	return i.createContainer(ctx, services, spec)
+}
+
+// adoptContainer takes over a running container with the given configuration hash.
+// It reports whether such a container was found.
+func (i *Instance) adoptContainer(ctx context.Context, services []Service, hash string) (bool, error) {
+	containers, err := i.cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", labelConfigHash+"="+hash),
+			filters.Arg("status", "running"),
+		),
+	})
+	if err != nil {
+		return false, fmt.Errorf("localstack: could not list containers: %w", err)
+	}
+	if len(containers) == 0 {
+		return false, nil
+	}
+
+	containerId := containers[0].ID
+	i.log.Infof("reusing running localstack %s", containerId)
+	i.setContainerId(containerId)
+	return true, i.mapPorts(ctx, services, containerId, 0)
+}
+
+func mergeLabels(labels ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, l := range labels {
+		for k, v := range l {
+			merged[k] = v
+		}
+	}
+	return merged
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_startLocalstack_Reuse_AdoptsRunningContainer(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerListReturns([]container.Summary{{ID: "running"}}, nil)
+	f.ContainerInspectReturns(container.InspectResponse{NetworkSettings: &container.NetworkSettings{
+		// will remove when removed
+		NetworkSettingsBase: container.NetworkSettingsBase{ //nolint:staticcheck
+			Ports: nat.PortMap{nat.Port(FixedPort.Port): {{HostPort: "1234"}}},
+		},
+	}}, nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), fixedPort: true, reuse: true}
+
+	require.NoError(t, i.startLocalstack(context.Background()))
+
+	require.Equal(t, "running", i.getContainerId())
+	require.Equal(t, "localhost:1234", i.Endpoint(S3))
+	require.Equal(t, 0, f.ImageInspectCallCount())
+	require.Equal(t, 0, f.ContainerCreateCallCount())
+	require.Equal(t, 0, f.ContainerStartCallCount())
+	_, options := f.ContainerListArgsForCall(0)
+	require.True(t, options.Filters.Contains("label"))
+	require.True(t, options.Filters.ExactMatch("status", "running"))
+}
+
+func TestInstance_Start_Reuse_RenewsLeaseOfAdoptedContainer(t *testing.T) {
+	t.Parallel()
+	f := startableFake(t)
+	f.ContainerListReturns([]container.Summary{{ID: "running"}}, nil)
+	f.ContainerExecCreateReturns(container.ExecCreateResponse{ID: "exec"}, nil)
+	i := &Instance{cli: f, log: logrus.StandardLogger(), fixedPort: true, reuse: true, lease: defaultReuseLease, portMapping: map[Service]string{}}
+	t.Cleanup(func() { require.NoError(t, i.Stop()) })
+
+	require.NoError(t, i.Start())
+
+	require.Equal(t, 0, f.ContainerCreateCallCount())
+	require.Equal(t, 1, f.ContainerExecCreateCallCount())
+	_, containerId, options := f.ContainerExecCreateArgsForCall(0)
+	require.Equal(t, "running", containerId)
+	require.Equal(t, []string{"touch", heartbeatFile}, options.Cmd)
+	require.Equal(t, 1, f.ContainerExecStartCallCount())
+}
+
+func TestInstance_startLocalstack_Reuse_LabelsNewContainer(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerCreateReturns(container.CreateResponse{}, errors.New("can't create"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), reuse: true, labels: map[string]string{"user": "label"}}
+
+	require.EqualError(t, i.startLocalstack(context.Background()), "localstack: could not create container: can't create")
+
+	_, config, _, _, _, _ := f.ContainerCreateArgsForCall(0)
+	require.Equal(t, "label", config.Labels["user"])
+	require.NotEmpty(t, config.Labels[labelConfigHash])
+	_, options := f.ContainerListArgsForCall(0)
+	require.True(t, options.Filters.ExactMatch("label", labelConfigHash+"="+config.Labels[labelConfigHash]))
+	require.Equal(t, map[string]string{"user": "label"}, i.labels)
+}
+
+func TestInstance_startLocalstack_Reuse_Fails(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerListReturns(nil, errors.New("can't list"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), reuse: true}
+
+	require.EqualError(t, i.startLocalstack(context.Background()), "localstack: could not list containers: can't list")
+	require.Equal(t, 0, f.ContainerCreateCallCount())
+}
+
+func TestInstance_Stop_Reuse_KeepsContainerRunning(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	i := &Instance{cli: f, log: logrus.StandardLogger(), containerId: "something", reuse: true}
+
+	require.NoError(t, i.Stop())
+
+	require.Empty(t, i.getContainerId())
+	require.Equal(t, 0, f.ContainerStopCallCount())
+}
+
+func TestInstance_configHash(t *testing.T) {
+	t.Parallel()
+	spec := containerSpec{
+		Config:     &container.Config{Image: "localstack/localstack:latest", Env: []string{"SERVICES=dynamodb,s3"}},
+		HostConfig: &container.HostConfig{AutoRemove: true},
+	}
+
+	first, err := (&Instance{version: "1.0.0"}).configHash(spec)
+	require.NoError(t, err)
+	second, err := (&Instance{version: "1.0.0"}).configHash(spec)
+	require.NoError(t, err)
+	other, err := (&Instance{version: "2.0.0"}).configHash(spec)
+	require.NoError(t, err)
+
+	require.Equal(t, first, second)
+	require.NotEqual(t, first, other)
+}
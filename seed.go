@@ -0,0 +1,397 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamotypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretstypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"gopkg.in/yaml.v3"
+)
+
+// WithSeed configures resources that are created when the instance is started.
+// See Seed for the format of the manifest.
+// The manifest is read once, so that the option can be used for several instances (like by Pool).
+// An invalid manifest is rejected when creating the instance.
+func WithSeed(manifest io.Reader) InstanceOption {
+	seeds, err := parseSeedManifest(manifest)
+	return func(i *Instance) {
+		i.seeds, i.seedErr = seeds, err
+	}
+}
+
+// Seed creates the resources described by the given YAML or JSON manifest.
+// Existing resources are kept, so that seeding can be repeated.
+//
+//	s3:
+//	  buckets:
+//	    - name: documents
+//	      objects: testdata/documents # directory containing the objects
+//	sqs:
+//	  queues:
+//	    - name: orders-dlq.fifo
+//	      fifo: true
+//	    - name: orders.fifo
+//	      fifo: true
+//	      attributes:
+//	        VisibilityTimeout: "30"
+//	      deadLetter:
+//	        queue: orders-dlq.fifo
+//	        maxReceiveCount: 3
+//	sns:
+//	  topics:
+//	    - name: events.fifo
+//	      fifo: true
+//	      subscriptions:
+//	        - protocol: sqs
+//	          queue: orders.fifo # or endpoint: for other protocols
+//	dynamodb:
+//	  tables:
+//	    - name: users
+//	      hashKey: {name: id, type: S}
+//	      rangeKey: {name: created, type: N}
+//	ssm:
+//	  parameters:
+//	    - name: /app/key
+//	      value: value
+//	      type: SecureString
+//	secretsmanager:
+//	  secrets:
+//	    - name: database
+//	      value: password
+func (i *Instance) Seed(ctx context.Context, manifest io.Reader) error {
+	m, err := parseSeedManifest(manifest)
+	if err != nil {
+		return err
+	}
+	return i.seed(ctx, m)
+}
+
+type seedManifest struct {
+	S3 struct {
+		Buckets []struct {
+			Name    string `yaml:"name"`
+			Objects string `yaml:"objects"`
+		} `yaml:"buckets"`
+	} `yaml:"s3"`
+	SQS struct {
+		Queues []seedQueue `yaml:"queues"`
+	} `yaml:"sqs"`
+	SNS struct {
+		Topics []struct {
+			Name          string `yaml:"name"`
+			FIFO          bool   `yaml:"fifo"`
+			Subscriptions []struct {
+				Protocol string `yaml:"protocol"`
+				Endpoint string `yaml:"endpoint"`
+				Queue    string `yaml:"queue"`
+			} `yaml:"subscriptions"`
+		} `yaml:"topics"`
+	} `yaml:"sns"`
+	DynamoDB struct {
+		Tables []struct {
+			Name     string       `yaml:"name"`
+			HashKey  seedTableKey `yaml:"hashKey"`
+			RangeKey seedTableKey `yaml:"rangeKey"`
+		} `yaml:"tables"`
+	} `yaml:"dynamodb"`
+	SSM struct {
+		Parameters []struct {
+			Name  string `yaml:"name"`
+			Value string `yaml:"value"`
+			Type  string `yaml:"type"`
+		} `yaml:"parameters"`
+	} `yaml:"ssm"`
+	SecretsManager struct {
+		Secrets []struct {
+			Name  string `yaml:"name"`
+			Value string `yaml:"value"`
+		} `yaml:"secrets"`
+	} `yaml:"secretsmanager"`
+}
+
+type seedQueue struct {
+	Name       string            `yaml:"name"`
+	FIFO       bool              `yaml:"fifo"`
+	Attributes map[string]string `yaml:"attributes"`
+	DeadLetter *struct {
+		Queue           string `yaml:"queue"`
+		MaxReceiveCount int    `yaml:"maxReceiveCount"`
+	} `yaml:"deadLetter"`
+}
+
+type seedTableKey struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+}
+
+func parseSeedManifest(manifest io.Reader) (*seedManifest, error) {
+	var m seedManifest
+	if err := yaml.NewDecoder(manifest).Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("localstack: could not parse seed manifest: %w", err)
+	}
+	return &m, nil
+}
+
+func (i *Instance) seed(ctx context.Context, m *seedManifest) error {
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion("us-east-1"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("dummy", "dummy", "dummy")),
+	)
+	if err != nil {
+		return fmt.Errorf("localstack: could not configure seeding: %w", err)
+	}
+	queueArns, err := i.seedSQS(ctx, sqs.NewFromConfig(cfg, sqs.WithEndpointResolverV2(NewSqsResolverV2(i))), m)
+	if err != nil {
+		return fmt.Errorf("localstack: could not seed sqs: %w", err)
+	}
+	if err := i.seedSNS(ctx, sns.NewFromConfig(cfg, sns.WithEndpointResolverV2(NewSnsResolverV2(i))), m, queueArns); err != nil {
+		return fmt.Errorf("localstack: could not seed sns: %w", err)
+	}
+	if err := i.seedS3(ctx, s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// path style, as buckets can't be resolved as subdomains of localhost
+		o.BaseEndpoint = aws.String(i.EndpointV2(S3))
+		o.UsePathStyle = true
+	}), m); err != nil {
+		return fmt.Errorf("localstack: could not seed s3: %w", err)
+	}
+	if err := i.seedDynamoDB(ctx, dynamodb.NewFromConfig(cfg, dynamodb.WithEndpointResolverV2(NewDynamoDbResolverV2(i))), m); err != nil {
+		return fmt.Errorf("localstack: could not seed dynamodb: %w", err)
+	}
+	if err := i.seedSSM(ctx, ssm.NewFromConfig(cfg, ssm.WithEndpointResolverV2(NewSsmResolverV2(i))), m); err != nil {
+		return fmt.Errorf("localstack: could not seed ssm: %w", err)
+	}
+	if err := i.seedSecretsManager(ctx, secretsmanager.NewFromConfig(cfg, secretsmanager.WithEndpointResolverV2(NewSecretsManagerResolverV2(i))), m); err != nil {
+		return fmt.Errorf("localstack: could not seed secretsmanager: %w", err)
+	}
+	return nil
+}
+
+// seedSQS creates the queues and returns their ARNs by name.
+// Dead-letter queues are created first, so that they can be referenced by the redrive policy.
+func (i *Instance) seedSQS(ctx context.Context, client *sqs.Client, m *seedManifest) (map[string]string, error) {
+	arns := make(map[string]string, len(m.SQS.Queues))
+	var redriven []seedQueue
+	for _, queue := range m.SQS.Queues {
+		if queue.DeadLetter != nil {
+			redriven = append(redriven, queue)
+			continue
+		}
+		if err := createQueue(ctx, client, queue, nil, arns); err != nil {
+			return nil, err
+		}
+	}
+	for _, queue := range redriven {
+		target, exists := arns[queue.DeadLetter.Queue]
+		if !exists {
+			return nil, fmt.Errorf("unknown dead-letter queue %q of %q", queue.DeadLetter.Queue, queue.Name)
+		}
+		policy, err := json.Marshal(map[string]string{
+			"deadLetterTargetArn": target,
+			"maxReceiveCount":     strconv.Itoa(queue.DeadLetter.MaxReceiveCount),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := createQueue(ctx, client, queue, map[string]string{
+			string(sqstypes.QueueAttributeNameRedrivePolicy): string(policy),
+		}, arns); err != nil {
+			return nil, err
+		}
+	}
+	return arns, nil
+}
+
+func createQueue(ctx context.Context, client *sqs.Client, queue seedQueue, attributes map[string]string, arns map[string]string) error {
+	merged := map[string]string{}
+	maps.Copy(merged, queue.Attributes)
+	maps.Copy(merged, attributes)
+	if queue.FIFO {
+		merged[string(sqstypes.QueueAttributeNameFifoQueue)] = "true"
+	}
+	created, err := client.CreateQueue(ctx, &sqs.CreateQueueInput{
+		QueueName:  aws.String(queue.Name),
+		Attributes: merged,
+	})
+	if err != nil {
+		return err
+	}
+	res, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       created.QueueUrl,
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return err
+	}
+	arns[queue.Name] = res.Attributes[string(sqstypes.QueueAttributeNameQueueArn)]
+	return nil
+}
+
+func (i *Instance) seedSNS(ctx context.Context, client *sns.Client, m *seedManifest, queueArns map[string]string) error {
+	for _, topic := range m.SNS.Topics {
+		input := &sns.CreateTopicInput{Name: aws.String(topic.Name)}
+		if topic.FIFO {
+			input.Attributes = map[string]string{"FifoTopic": "true"}
+		}
+		created, err := client.CreateTopic(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, subscription := range topic.Subscriptions {
+			endpoint := subscription.Endpoint
+			if subscription.Queue != "" {
+				arn, exists := queueArns[subscription.Queue]
+				if !exists {
+					return fmt.Errorf("unknown queue %q subscribing %q", subscription.Queue, topic.Name)
+				}
+				endpoint = arn
+			}
+			if _, err := client.Subscribe(ctx, &sns.SubscribeInput{
+				TopicArn: created.TopicArn,
+				Protocol: aws.String(subscription.Protocol),
+				Endpoint: aws.String(endpoint),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (i *Instance) seedS3(ctx context.Context, client *s3.Client, m *seedManifest) error {
+	for _, bucket := range m.S3.Buckets {
+		if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket.Name)}); err != nil {
+			return err
+		}
+		if bucket.Objects == "" {
+			continue
+		}
+		if err := filepath.WalkDir(bucket.Objects, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			key, err := filepath.Rel(bucket.Objects, path)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path) //nolint:gosec // objects are provided by the manifest
+			if err != nil {
+				return err
+			}
+			defer logClose(f)
+			_, err = client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(bucket.Name),
+				Key:    aws.String(filepath.ToSlash(key)),
+				Body:   f,
+			})
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Instance) seedDynamoDB(ctx context.Context, client *dynamodb.Client, m *seedManifest) error {
+	for _, table := range m.DynamoDB.Tables {
+		input := &dynamodb.CreateTableInput{
+			TableName:   aws.String(table.Name),
+			BillingMode: dynamotypes.BillingModePayPerRequest,
+		}
+		// DynamoDB requires the hash key before the range key.
+		for _, key := range [...]struct {
+			keyType dynamotypes.KeyType
+			seedTableKey
+		}{
+			{dynamotypes.KeyTypeHash, table.HashKey},
+			{dynamotypes.KeyTypeRange, table.RangeKey},
+		} {
+			if key.Name == "" {
+				continue
+			}
+			input.AttributeDefinitions = append(input.AttributeDefinitions, dynamotypes.AttributeDefinition{
+				AttributeName: aws.String(key.Name),
+				AttributeType: dynamotypes.ScalarAttributeType(key.Type),
+			})
+			input.KeySchema = append(input.KeySchema, dynamotypes.KeySchemaElement{
+				AttributeName: aws.String(key.Name),
+				KeyType:       key.keyType,
+			})
+		}
+		_, err := client.CreateTable(ctx, input)
+		if inUse := (*dynamotypes.ResourceInUseException)(nil); err != nil && !errors.As(err, &inUse) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Instance) seedSSM(ctx context.Context, client *ssm.Client, m *seedManifest) error {
+	for _, parameter := range m.SSM.Parameters {
+		parameterType := ssmtypes.ParameterTypeString
+		if parameter.Type != "" {
+			parameterType = ssmtypes.ParameterType(parameter.Type)
+		}
+		if _, err := client.PutParameter(ctx, &ssm.PutParameterInput{
+			Name:      aws.String(parameter.Name),
+			Value:     aws.String(parameter.Value),
+			Type:      parameterType,
+			Overwrite: aws.Bool(true),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Instance) seedSecretsManager(ctx context.Context, client *secretsmanager.Client, m *seedManifest) error {
+	for _, secret := range m.SecretsManager.Secrets {
+		_, err := client.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+			Name:         aws.String(secret.Name),
+			SecretString: aws.String(secret.Value),
+		})
+		if exists := (*secretstypes.ResourceExistsException)(nil); errors.As(err, &exists) {
+			_, err = client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+				SecretId:     aws.String(secret.Name),
+				SecretString: aws.String(secret.Value),
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_Seed(t *testing.T) {
+	t.Parallel()
+	aws := &fakeAWS{}
+	server := httptest.NewServer(aws)
+	t.Cleanup(server.Close)
+	manifest, err := os.Open("testdata/seed/manifest.yml")
+	require.NoError(t, err)
+	defer logClose(manifest)
+
+	require.NoError(t, runningInstance(server).Seed(context.Background(), manifest))
+
+	require.Equal(t, []string{
+		`AmazonSQS.CreateQueue orders-dlq.fifo {"FifoQueue":"true"}`,
+		"AmazonSQS.GetQueueAttributes",
+		`AmazonSQS.CreateQueue orders.fifo {"FifoQueue":"true","RedrivePolicy":"{\"deadLetterTargetArn\":\"arn:aws:sqs:us-east-1:000000000000:orders-dlq.fifo\",\"maxReceiveCount\":\"3\"}"}`,
+		"AmazonSQS.GetQueueAttributes",
+		"CreateTopic events.fifo FifoTopic=true",
+		"Subscribe sqs arn:aws:sqs:us-east-1:000000000000:orders.fifo",
+		"PUT /documents",
+		"PUT /documents/hello.txt hello",
+		"PUT /documents/nested/doc.json {}",
+		`DynamoDB_20120810.CreateTable users {"AttributeDefinitions":[{"AttributeName":"id","AttributeType":"S"}],"KeySchema":[{"AttributeName":"id","KeyType":"HASH"}]}`,
+		`DynamoDB_20120810.CreateTable events {"AttributeDefinitions":[{"AttributeName":"id","AttributeType":"S"},{"AttributeName":"created","AttributeType":"N"}],"KeySchema":[{"AttributeName":"id","KeyType":"HASH"},{"AttributeName":"created","KeyType":"RANGE"}]}`,
+		"AmazonSSM.PutParameter",
+		"secretsmanager.CreateSecret",
+	}, aws.calls)
+}
+
+func TestInstance_Seed_Fails(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when     string
+		manifest string
+		expect   string
+	}{
+		{
+			when:     "the manifest is invalid",
+			manifest: "sqs: [",
+			expect:   "localstack: could not parse seed manifest: yaml: line 1: did not find expected node content",
+		},
+		{
+			when:     "the dead-letter queue is unknown",
+			manifest: `{"sqs": {"queues": [{"name": "orders", "deadLetter": {"queue": "missing"}}]}}`,
+			expect:   `localstack: could not seed sqs: unknown dead-letter queue "missing" of "orders"`,
+		},
+		{
+			when:     "the subscribed queue is unknown",
+			manifest: `{"sns": {"topics": [{"name": "events", "subscriptions": [{"protocol": "sqs", "queue": "missing"}]}]}}`,
+			expect:   `localstack: could not seed sns: unknown queue "missing" subscribing "events"`,
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(&fakeAWS{})
+			t.Cleanup(server.Close)
+			require.EqualError(t, runningInstance(server).Seed(context.Background(), strings.NewReader(s.manifest)), s.expect)
+		})
+	}
+}
+
+func TestNewInstance_SeedManifest_SharedOption(t *testing.T) {
+	t.Parallel()
+	opt := WithSeed(strings.NewReader("ssm: {parameters: [{name: /app/key, value: value}]}"))
+	first, err := NewAuthenticatedInstance("token", opt)
+	require.NoError(t, err)
+	second, err := NewAuthenticatedInstance("token", opt)
+	require.NoError(t, err)
+	require.Len(t, first.seeds.SSM.Parameters, 1)
+	require.Equal(t, first.seeds, second.seeds)
+}
+
+func TestNewInstance_Fails_SeedManifest(t *testing.T) {
+	t.Parallel()
+	_, err := NewAuthenticatedInstance("token", WithSeed(strings.NewReader("sqs: [")))
+	require.EqualError(t, err, "localstack: could not parse seed manifest: yaml: line 1: did not find expected node content")
+}
+
+// fakeAWS answers the calls of seeding like localstack and records them.
+type fakeAWS struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	target := r.Header.Get("X-Amz-Target")
+	call := target
+	switch {
+	case target == "AmazonSQS.CreateQueue":
+		var input struct {
+			QueueName  string
+			Attributes map[string]string
+		}
+		_ = json.Unmarshal(body, &input)
+		call += " " + input.QueueName
+		if len(input.Attributes) > 0 {
+			attributes, _ := json.Marshal(input.Attributes)
+			call += " " + string(attributes)
+		}
+		_, _ = w.Write([]byte(`{"QueueUrl": "http://localhost/000000000000/` + input.QueueName + `"}`))
+	case target == "AmazonSQS.GetQueueAttributes":
+		var input struct{ QueueUrl string }
+		_ = json.Unmarshal(body, &input)
+		name := input.QueueUrl[strings.LastIndex(input.QueueUrl, "/")+1:]
+		_, _ = w.Write([]byte(`{"Attributes": {"QueueArn": "arn:aws:sqs:us-east-1:000000000000:` + name + `"}}`))
+	case target == "DynamoDB_20120810.CreateTable":
+		var input struct {
+			TableName            string
+			AttributeDefinitions json.RawMessage
+			KeySchema            json.RawMessage
+		}
+		_ = json.Unmarshal(body, &input)
+		keys, _ := json.Marshal(struct {
+			AttributeDefinitions json.RawMessage
+			KeySchema            json.RawMessage
+		}{input.AttributeDefinitions, input.KeySchema})
+		call += " " + input.TableName + " " + string(keys)
+		_, _ = w.Write([]byte("{}"))
+	case target != "":
+		_, _ = w.Write([]byte("{}"))
+	case r.Method == http.MethodPost:
+		form, _ := url.ParseQuery(string(body))
+		action := form.Get("Action")
+		call = action + " " + form.Get("Name")
+		if key := form.Get("Attributes.entry.1.key"); key != "" {
+			call += " " + key + "=" + form.Get("Attributes.entry.1.value")
+		}
+		if action == "Subscribe" {
+			call = action + " " + form.Get("Protocol") + " " + form.Get("Endpoint")
+		}
+		_, _ = w.Write([]byte(`<` + action + `Response><` + action + `Result><TopicArn>arn:aws:sns:us-east-1:000000000000:topic</TopicArn></` + action + `Result></` + action + `Response>`))
+	default:
+		call = strings.TrimSpace(r.Method + " " + r.URL.Path + " " + string(body))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
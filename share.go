@@ -0,0 +1,136 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// shareReferences is persisted next to the lock, to count the processes using a shared container.
+type shareReferences struct {
+	ContainerId string `json:"containerId"`
+	Count       int    `json:"count"`
+}
+
+// shareLock is an exclusive lock across processes for a shared container configuration.
+type shareLock struct {
+	file *os.File
+}
+
+func lockShare(hash string) (*shareLock, error) {
+	path := filepath.Join(os.TempDir(), "go-localstack-"+hash+".lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		logClose(f)
+		return nil, err
+	}
+	return &shareLock{file: f}, nil
+}
+
+func (l *shareLock) references() (shareReferences, error) {
+	var refs shareReferences
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return refs, err
+	}
+	content, err := io.ReadAll(l.file)
+	if err != nil || len(content) == 0 {
+		return refs, err
+	}
+	if err := json.Unmarshal(content, &refs); err != nil {
+		// a corrupted file can't reference any running container
+		return shareReferences{}, nil
+	}
+	return refs, nil
+}
+
+func (l *shareLock) save(refs shareReferences) error {
+	content, err := json.Marshal(refs)
+	if err != nil {
+		return err
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = l.file.WriteAt(content, 0)
+	return err
+}
+
+func (l *shareLock) Close() error {
+	if err := unlockFile(l.file); err != nil {
+		logClose(l.file)
+		return err
+	}
+	return l.file.Close()
+}
+
+// shareContainer attaches to the shared container or starts it, when no other process did.
+func (i *Instance) shareContainer(ctx context.Context, services []Service, hash string, spec containerSpec) error {
+	lock, err := lockShare(hash)
+	if err != nil {
+		return fmt.Errorf("localstack: could not lock shared container: %w", err)
+	}
+	defer logClose(lock)
+
+	refs, err := lock.references()
+	if err != nil {
+		return fmt.Errorf("localstack: could not read shared container references: %w", err)
+	}
+
+	if err := i.reuseContainer(ctx, services, hash, spec); err != nil {
+		return err
+	}
+
+	i.containerIdMutex.Lock()
+	defer i.containerIdMutex.Unlock()
+	i.shareHash = hash
+	if refs.ContainerId == i.containerId {
+		refs.Count++
+	} else {
+		refs = shareReferences{ContainerId: i.containerId, Count: 1}
+	}
+	return lock.save(refs)
+}
+
+// releaseShare removes the reference to the shared container
+// and reports whether it was the last one.
+// A container, which was replaced by another one, is stale and keeps the references of the other one.
+func releaseShare(hash string, containerId string) (bool, error) {
+	lock, err := lockShare(hash)
+	if err != nil {
+		return false, err
+	}
+	defer logClose(lock)
+
+	refs, err := lock.references()
+	if err != nil {
+		return false, err
+	}
+	if refs.ContainerId != containerId {
+		return true, nil
+	}
+	if refs.Count > 1 {
+		refs.Count--
+		return false, lock.save(refs)
+	}
+	return true, lock.save(shareReferences{})
+}
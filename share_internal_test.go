@@ -0,0 +1,83 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_startLocalstack_Shared_CountsReferences(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerListReturns([]container.Summary{{ID: "shared"}}, nil)
+	f.ContainerInspectReturns(container.InspectResponse{NetworkSettings: &container.NetworkSettings{
+		// will remove when removed
+		NetworkSettingsBase: container.NetworkSettingsBase{ //nolint:staticcheck
+			Ports: nat.PortMap{nat.Port(FixedPort.Port): {{HostPort: "1234"}}},
+		},
+	}}, nil)
+	first := &Instance{cli: f, log: logrus.StandardLogger(), fixedPort: true, shared: true}
+	second := &Instance{cli: f, log: logrus.StandardLogger(), fixedPort: true, shared: true}
+
+	require.NoError(t, first.startLocalstack(context.Background()))
+	require.NoError(t, second.startLocalstack(context.Background()))
+	require.Equal(t, "shared", second.getContainerId())
+	require.Equal(t, "localhost:1234", second.Endpoint(S3))
+
+	require.NoError(t, first.Stop())
+	require.Equal(t, 0, f.ContainerStopCallCount())
+	require.Empty(t, first.getContainerId())
+
+	require.NoError(t, second.Stop())
+	require.Equal(t, 1, f.ContainerStopCallCount())
+	_, containerId, _ := f.ContainerStopArgsForCall(0)
+	require.Equal(t, "shared", containerId)
+}
+
+func TestInstance_startLocalstack_Shared_KeepsReferencesOfReplacingContainer(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	lock, err := lockShare("stale")
+	require.NoError(t, err)
+	require.NoError(t, lock.save(shareReferences{ContainerId: "gone", Count: 3}))
+	require.NoError(t, lock.Close())
+
+	last, err := releaseShare("stale", "current")
+	require.NoError(t, err)
+	require.True(t, last)
+
+	lock, err = lockShare("stale")
+	require.NoError(t, err)
+	defer logClose(lock)
+	refs, err := lock.references()
+	require.NoError(t, err)
+	require.Equal(t, shareReferences{ContainerId: "gone", Count: 3}, refs)
+}
+
+func TestInstance_Stop_Shared_Fails(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	f := &internalfakes.FakeDockerClient{}
+	f.ContainerStopReturns(errors.New("can't stop"))
+	i := &Instance{cli: f, log: logrus.StandardLogger(), containerId: "something", shared: true, shareHash: "failing"}
+
+	require.EqualError(t, i.Stop(), "can't stop")
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	// signalInstances are the running instances, which are stopped on SIGINT or SIGTERM.
+	signalInstances      = map[*Instance]struct{}{}
+	signalInstancesMutex sync.Mutex
+	signalHandlerOnce    sync.Once
+)
+
+// WithSignalCleanup configures the instance to be stopped, when the process receives SIGINT or SIGTERM,
+// like on Ctrl-C during go test, where t.Cleanup isn't run anymore.
+// The signal is raised again afterwards, so that the process terminates as usual.
+func WithSignalCleanup() InstanceOption {
+	return func(i *Instance) {
+		i.signalCleanup = true
+	}
+}
+
+// registerSignalCleanup adds the instance to the ones stopped on signals.
+func (i *Instance) registerSignalCleanup() {
+	if !i.signalCleanup {
+		return
+	}
+	signalHandlerOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		go stopOnSignal(signals, raise)
+	})
+	signalInstancesMutex.Lock()
+	defer signalInstancesMutex.Unlock()
+	signalInstances[i] = struct{}{}
+}
+
+// unregisterSignalCleanup removes the instance from the ones stopped on signals.
+func (i *Instance) unregisterSignalCleanup() {
+	signalInstancesMutex.Lock()
+	defer signalInstancesMutex.Unlock()
+	delete(signalInstances, i)
+}
+
+// stopOnSignal stops all registered instances on the first signal and raises it again.
+func stopOnSignal(signals <-chan os.Signal, raise func(os.Signal)) {
+	sig := <-signals
+	signalInstancesMutex.Lock()
+	instances := make([]*Instance, 0, len(signalInstances))
+	for i := range signalInstances {
+		instances = append(instances, i)
+	}
+	signalInstancesMutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, i := range instances {
+		wg.Go(func() {
+			if err := i.Stop(); err != nil {
+				i.log.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	raise(sig)
+}
+
+// raise restores the default behaviour of the signal and sends it to the process.
+func raise(sig os.Signal) {
+	signal.Reset(sig)
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_registerSignalCleanup(t *testing.T) {
+	signalHandlerOnce.Do(func() {}) // not handling the signals of the tests
+	f := &internalfakes.FakeDockerClient{}
+	i := &Instance{cli: f, log: logrus.StandardLogger(), containerId: "running"}
+	WithSignalCleanup()(i)
+
+	i.registerSignalCleanup()
+	require.Contains(t, signalInstances, i)
+
+	require.NoError(t, i.Stop())
+	require.NotContains(t, signalInstances, i)
+}
+
+func TestInstance_registerSignalCleanup_Disabled(t *testing.T) {
+	i := &Instance{log: logrus.StandardLogger()}
+	i.registerSignalCleanup()
+	require.NotContains(t, signalInstances, i)
+}
+
+func Test_stopOnSignal(t *testing.T) {
+	signalHandlerOnce.Do(func() {})
+	first := &internalfakes.FakeDockerClient{}
+	second := &internalfakes.FakeDockerClient{}
+	instances := []*Instance{
+		{cli: first, log: logrus.StandardLogger(), containerId: "first", signalCleanup: true},
+		{cli: second, log: logrus.StandardLogger(), containerId: "second", signalCleanup: true},
+	}
+	for _, i := range instances {
+		i.registerSignalCleanup()
+	}
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGINT
+	var raised os.Signal
+
+	stopOnSignal(signals, func(sig os.Signal) {
+		raised = sig
+	})
+
+	require.Equal(t, syscall.SIGINT, raised)
+	require.Equal(t, 1, first.ContainerStopCallCount())
+	require.Equal(t, 1, second.ContainerStopCallCount())
+	for _, i := range instances {
+		require.NotContains(t, signalInstances, i)
+		require.Empty(t, i.getContainerId())
+	}
+}
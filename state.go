@@ -0,0 +1,66 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reset discards the resources of the given services or of all services, when none are given.
+// It returns when localstack is available again and requires version 1.0.0 or later.
+// Resources of WithSeed and WithInitScripts are discarded as well.
+func (i *Instance) Reset(ctx context.Context, services ...Service) error {
+	if err := i.resetState(ctx, services...); err != nil {
+		return err
+	}
+	return i.waitToBeAvailable(ctx)
+}
+
+func (i *Instance) resetState(ctx context.Context, services ...Service) error {
+	if i.getContainerId() == "" {
+		return errors.New("localstack: instance is not running")
+	}
+	if !i.hasHealthEndpoint() {
+		return fmt.Errorf("localstack: resetting state requires version %s or later", internalAPIVersion)
+	}
+	names := []string{""}
+	if len(services) > 0 && !containsService(services, FixedPort) {
+		names = make([]string, 0, len(services))
+		for _, service := range services {
+			names = append(names, service.localstackName())
+		}
+	}
+	for _, name := range names {
+		if err := i.Admin().ResetState(ctx, name); err != nil {
+			return fmt.Errorf("localstack: could not reset state: %w", err)
+		}
+	}
+	return nil
+}
+
+// localstackName is the name localstack uses for the service.
+func (s Service) localstackName() string {
+	switch s {
+	case CloudWatchLogs:
+		return "logs"
+	case CloudWatchEvents:
+		return "events"
+	default:
+		return strings.ToLower(s.Name)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_Reset(t *testing.T) {
+	t.Parallel()
+	for _, scenario := range [...]struct {
+		when     string
+		services []Service
+		expect   []string
+	}{
+		{
+			when:   "resetting all services",
+			expect: []string{"/_localstack/state/reset"},
+		},
+		{
+			when:     "resetting the fixed port",
+			services: []Service{FixedPort},
+			expect:   []string{"/_localstack/state/reset"},
+		},
+		{
+			when:     "resetting individual services",
+			services: []Service{S3, CloudWatchLogs, DynamoDB},
+			expect: []string{
+				"/_localstack/state/s3/reset",
+				"/_localstack/state/logs/reset",
+				"/_localstack/state/dynamodb/reset",
+			},
+		},
+	} {
+		s := scenario
+		t.Run(s.when, func(t *testing.T) {
+			t.Parallel()
+			var (
+				mu    sync.Mutex
+				paths []string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/_localstack/state") {
+					mu.Lock()
+					paths = append(paths, r.URL.Path)
+					mu.Unlock()
+				}
+				_, _ = w.Write([]byte("{}"))
+			}))
+			t.Cleanup(server.Close)
+
+			require.NoError(t, runningInstance(server).Reset(context.Background(), s.services...))
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Equal(t, s.expect, paths)
+		})
+	}
+}
+
+func TestInstance_Reset_Fails(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	require.EqualError(t, runningInstance(server).Reset(context.Background()),
+		"localstack: could not reset state: /_localstack/state/reset 500 Internal Server Error")
+}
+
+func TestInstance_Reset_Fails_NotRunning(t *testing.T) {
+	t.Parallel()
+	i := &Instance{log: logrus.StandardLogger()}
+	require.EqualError(t, i.Reset(context.Background()), "localstack: instance is not running")
+}
+
+func TestInstance_Reset_Fails_WithoutInternalAPI(t *testing.T) {
+	t.Parallel()
+	for _, i := range []*Instance{
+		{log: logrus.StandardLogger(), containerId: "running"},
+		{log: logrus.StandardLogger(), containerId: "running", fixedPort: true, legacyHealth: true},
+	} {
+		require.EqualError(t, i.Reset(context.Background()), "localstack: resetting state requires version 1.0.0 or later")
+	}
+}
+
+// runningInstance returns an instance, which is running at the given server.
+func runningInstance(server *httptest.Server) *Instance {
+	return &Instance{
+		cli:         &internalfakes.FakeDockerClient{},
+		log:         logrus.StandardLogger(),
+		fixedPort:   true,
+		containerId: "running",
+		portMapping: map[Service]string{FixedPort: strings.TrimPrefix(server.URL, "http://")},
+	}
+}
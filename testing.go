@@ -0,0 +1,79 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StartT starts an authenticated instance for the given test and stops it when the test is cleaned up.
+// Logs, including the logs of the container, are written to t.Log.
+// The test is skipped when Docker is not reachable.
+func StartT(t testing.TB, authToken string, opts ...InstanceOption) *Instance {
+	t.Helper()
+	w := &testLogWriter{t: t}
+	logger := logrus.New()
+	logger.SetOutput(w)
+	logger.SetLevel(logrus.DebugLevel)
+
+	i, err := NewAuthenticatedInstanceWithContext(t.Context(), authToken, append([]InstanceOption{WithLogger(logger)}, opts...)...)
+	if errors.As(err, &dockerUnreachable{}) {
+		t.Skipf("localstack: docker is not reachable: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.cli.Ping(t.Context()); err != nil {
+		t.Skipf("localstack: docker is not reachable: %v", err)
+	}
+
+	t.Cleanup(func() {
+		defer w.close()
+		if err := i.stop(); err != nil {
+			t.Errorf("localstack: could not stop: %v", err)
+		}
+	})
+	if err := i.start(t.Context()); err != nil {
+		t.Fatalf("localstack: could not start: %v", err)
+	}
+	return i
+}
+
+// testLogWriter writes to t.Log until the test is done, as logging afterward panics.
+type testLogWriter struct {
+	t    testing.TB
+	mu   sync.Mutex
+	done bool
+}
+
+func (w *testLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.done {
+		w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	}
+	return len(p), nil
+}
+
+func (w *testLogWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+}
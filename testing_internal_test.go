@@ -0,0 +1,129 @@
+// Copyright 2021 - Lars Gohr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/elgohr/go-localstack/internal/internalfakes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartT_Skips_WithoutDocker(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.PingReturns(types.Ping{}, errors.New("not reachable"))
+	tb := &fakeTB{TB: t}
+
+	tb.run(func() {
+		StartT(tb, "token", func(i *Instance) { i.cli = f })
+	})
+
+	require.Equal(t, "localstack: docker is not reachable: not reachable", tb.skipped)
+	require.Empty(t, tb.cleanups)
+	require.Equal(t, 0, f.ContainerCreateCallCount())
+}
+
+func TestStartT_Fails_WithInvalidVersion(t *testing.T) {
+	t.Parallel()
+	tb := &fakeTB{TB: t}
+
+	tb.run(func() {
+		StartT(tb, "token", WithVersion("bad.version.34"))
+	})
+
+	require.Empty(t, tb.skipped)
+	require.Contains(t, tb.failed, `localstack: invalid version "bad.version.34" specified`)
+}
+
+func TestStartT_Fails_Start(t *testing.T) {
+	t.Parallel()
+	f := &internalfakes.FakeDockerClient{}
+	f.ImageInspectReturns(image.InspectResponse{}, errors.New("can't inspect"))
+	tb := &fakeTB{TB: t}
+
+	tb.run(func() {
+		StartT(tb, "token", func(i *Instance) { i.cli = f })
+	})
+
+	require.Equal(t, "localstack: could not start: localstack: could not inspect image: can't inspect", tb.failed)
+	require.Len(t, tb.cleanups, 1)
+}
+
+func TestTestLogWriter_StopsAfterClose(t *testing.T) {
+	t.Parallel()
+	tb := &fakeTB{TB: t}
+	w := &testLogWriter{t: tb}
+
+	_, err := w.Write([]byte("before\n"))
+	require.NoError(t, err)
+	w.close()
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"before"}, tb.logs)
+}
+
+type fakeTB struct {
+	testing.TB
+	skipped  string
+	failed   string
+	logs     []string
+	cleanups []func()
+}
+
+func (f *fakeTB) run(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Context() context.Context {
+	return context.Background()
+}
+
+func (f *fakeTB) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func (f *fakeTB) Log(args ...any) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func (f *fakeTB) Skipf(format string, args ...any) {
+	f.skipped = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func (f *fakeTB) Fatal(args ...any) {
+	f.failed = fmt.Sprint(args...)
+	runtime.Goexit()
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	f.failed = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}